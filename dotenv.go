@@ -0,0 +1,142 @@
+// Copyright (c) 2016 Kelsey Hightower and others. All rights reserved.
+// Use of this source code is governed by the MIT License that can be found in
+// the LICENSE file.
+
+package envconfig
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// A ProcessFunc populates the specified struct from a fixed set of
+// variables, with the same semantics as Process.
+type ProcessFunc func(prefix string, spec interface{}, opts ...Option) error
+
+// NewReader reads variables in dotenv format from r and returns a ProcessFunc
+// that processes specifications against them instead of the environment.
+// Each line holds a KEY=value pair, optionally preceded by "export"; blank
+// lines and lines starting with # are ignored. Values may be wrapped in
+// single quotes, taken literally, or double quotes, which allow Go escapes.
+// Lines may end in "\r\n" as well as "\n", so files written on Windows read
+// the same.
+func NewReader(r io.Reader) (ProcessFunc, error) {
+	env, err := readDotenv(r)
+	if err != nil {
+		return nil, err
+	}
+	return func(prefix string, spec interface{}, opts ...Option) error {
+		return ProcessWithEnv(env, prefix, spec, opts...)
+	}, nil
+}
+
+// ProcessReader reads variables in dotenv format from r, as NewReader does,
+// and populates the specified struct from them.
+func ProcessReader(r io.Reader, prefix string, spec interface{}, opts ...Option) error {
+	process, err := NewReader(r)
+	if err != nil {
+		return err
+	}
+	return process(prefix, spec, opts...)
+}
+
+// readDotenv parses the dotenv formatted variables in r.
+func readDotenv(r io.Reader) (map[string]string, error) {
+	env := make(map[string]string)
+	scanner := bufio.NewScanner(r)
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		line = strings.TrimPrefix(line, "export ")
+
+		kv := strings.SplitN(line, "=", 2)
+		key := strings.TrimSpace(kv[0])
+		if len(kv) != 2 || key == "" {
+			return nil, fmt.Errorf("envconfig: line %d: expected KEY=value, got %q", n, line)
+		}
+		value, err := unquoteDotenv(strings.TrimSpace(kv[1]))
+		if err != nil {
+			return nil, fmt.Errorf("envconfig: line %d: %v", n, err)
+		}
+		env[key] = value
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return env, nil
+}
+
+// unquoteDotenv removes the quotes around a dotenv value.
+func unquoteDotenv(value string) (string, error) {
+	if len(value) < 2 {
+		return value, nil
+	}
+	switch first, last := value[0], value[len(value)-1]; {
+	case first == '\'' && last == '\'':
+		return value[1 : len(value)-1], nil
+	case first == '"' && last == '"':
+		unquoted, err := strconv.Unquote(value)
+		if err != nil {
+			return "", fmt.Errorf("invalid quoted value %s", value)
+		}
+		return unquoted, nil
+	}
+	return value, nil
+}
+
+// GenerateEnvFile writes a sample file in dotenv format for the specified
+// struct, for operators to fill in. Each variable is preceded by its
+// description and by comments marking it required or secret, and is set to
+// its default. Secret variables are always left empty.
+func GenerateEnvFile(prefix string, spec interface{}, out io.Writer) error {
+	prefix = normalizePrefix(prefix)
+	infos, err := gatherInfo(prefix, spec)
+	if err != nil {
+		return err
+	}
+	registered := registeredDefaultsFor(spec)
+
+	w := bufio.NewWriter(out)
+	first := true
+	for _, info := range infos {
+		if info.Remainder {
+			continue
+		}
+		if !first {
+			w.WriteString("\n")
+		}
+		first = false
+
+		if desc := info.Tags.Get("desc"); desc != "" {
+			for _, line := range strings.Split(desc, "\n") {
+				fmt.Fprintf(w, "# %s\n", line)
+			}
+		}
+		if isTrue(info.Tags.Get("required")) {
+			w.WriteString("# required\n")
+		}
+		def := info.Tags.Get("default")
+		if def == "" {
+			def = registered[info.Path]
+		}
+		if isTrue(info.Tags.Get("secret")) {
+			w.WriteString("# secret\n")
+			def = ""
+		}
+		fmt.Fprintf(w, "%s=%s\n", info.Key, quoteDotenv(def))
+	}
+	return w.Flush()
+}
+
+// quoteDotenv quotes value when readDotenv would not read it back verbatim.
+func quoteDotenv(value string) string {
+	if value != strings.TrimSpace(value) || strings.ContainsAny(value, "\n\r") || strings.HasPrefix(value, "'") || strings.HasPrefix(value, `"`) {
+		return strconv.Quote(value)
+	}
+	return value
+}
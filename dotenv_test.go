@@ -0,0 +1,148 @@
+// Copyright (c) 2016 Kelsey Hightower and others. All rights reserved.
+// Use of this source code is governed by the MIT License that can be found in
+// the LICENSE file.
+
+package envconfig
+
+import (
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const testDotenv = `# application settings
+ENV_CONFIG_HOST=localhost
+export ENV_CONFIG_PORT = 8080
+
+ENV_CONFIG_GREETING="hello\tworld"
+ENV_CONFIG_PATTERN='a\tb'
+`
+
+func TestProcessReader(t *testing.T) {
+	var s struct {
+		Host     string
+		Port     int
+		Greeting string
+		Pattern  string
+	}
+	os.Clearenv()
+	os.Setenv("ENV_CONFIG_HOST", "ignored")
+	if err := ProcessReader(strings.NewReader(testDotenv), "env_config", &s); err != nil {
+		t.Fatal(err.Error())
+	}
+	if s.Host != "localhost" {
+		t.Errorf("expected %s, got %s", "localhost", s.Host)
+	}
+	if s.Port != 8080 {
+		t.Errorf("expected %d, got %d", 8080, s.Port)
+	}
+	if s.Greeting != "hello\tworld" {
+		t.Errorf("expected %q, got %q", "hello\tworld", s.Greeting)
+	}
+	if s.Pattern != `a\tb` {
+		t.Errorf("expected %q, got %q", `a\tb`, s.Pattern)
+	}
+}
+
+func TestNewReader(t *testing.T) {
+	process, err := NewReader(strings.NewReader(testDotenv))
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	for i := 0; i < 2; i++ {
+		var s struct {
+			Port int
+		}
+		if err := process("env_config", &s); err != nil {
+			t.Fatal(err.Error())
+		}
+		if s.Port != 8080 {
+			t.Errorf("expected %d, got %d", 8080, s.Port)
+		}
+	}
+}
+
+func TestReadDotenvCRLF(t *testing.T) {
+	want, err := readDotenv(strings.NewReader(testDotenv))
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	crlf := strings.ReplaceAll(testDotenv, "\n", "\r\n") + "\r\n"
+	got, err := readDotenv(strings.NewReader(crlf))
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("expected %q, got %q", want, got)
+	}
+}
+
+func TestProcessReaderError(t *testing.T) {
+	var s struct {
+		Port int
+	}
+	err := ProcessReader(strings.NewReader("ENV_CONFIG_HOST=localhost\nPORT\n"), "env_config", &s)
+	if experr := `envconfig: line 2: expected KEY=value, got "PORT"`; err == nil || err.Error() != experr {
+		t.Errorf("expected %s, got %v", experr, err)
+	}
+}
+
+func TestGenerateEnvFile(t *testing.T) {
+	var s struct {
+		Host     string `desc:"host to bind" default:"localhost"`
+		Port     int    `required:"true"`
+		Password string `secret:"true" default:"changeme"`
+		Greeting string `default:" hi "`
+	}
+	buf := new(strings.Builder)
+	if err := GenerateEnvFile("env_config", &s, buf); err != nil {
+		t.Fatal(err.Error())
+	}
+	want := `# host to bind
+ENV_CONFIG_HOST=localhost
+
+# required
+ENV_CONFIG_PORT=
+
+# secret
+ENV_CONFIG_PASSWORD=
+
+ENV_CONFIG_GREETING=" hi "
+`
+	if got := buf.String(); got != want {
+		t.Errorf("expected %q, got %q", want, got)
+	}
+
+	// the generated file reads back as the defaults
+	var back struct {
+		Host     string
+		Greeting string
+	}
+	if err := ProcessReader(strings.NewReader(want), "env_config", &back); err != nil {
+		t.Fatal(err.Error())
+	}
+	if back.Host != "localhost" || back.Greeting != " hi " {
+		t.Errorf("expected %q and %q, got %q and %q", "localhost", " hi ", back.Host, back.Greeting)
+	}
+}
+
+func TestProcessReaderExpandedDefault(t *testing.T) {
+	var s struct {
+		Cache string `default:"${HOME}/cache"`
+		Path  string
+	}
+	os.Clearenv()
+	os.Setenv("HOME", "/root")
+	// defaults are expanded from the file, and values are used verbatim
+	env := "HOME=/home/app\nENV_CONFIG_PATH=${HOME}/bin\n"
+	if err := ProcessReader(strings.NewReader(env), "env_config", &s); err != nil {
+		t.Fatal(err.Error())
+	}
+	if s.Cache != "/home/app/cache" {
+		t.Errorf("expected %q, got %q", "/home/app/cache", s.Cache)
+	}
+	if s.Path != "${HOME}/bin" {
+		t.Errorf("expected %q, got %q", "${HOME}/bin", s.Path)
+	}
+}
@@ -0,0 +1,96 @@
+// Copyright (c) 2016 Kelsey Hightower and others. All rights reserved.
+// Use of this source code is governed by the MIT License that can be found in
+// the LICENSE file.
+
+package envconfig
+
+import (
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// An enum holds the names registered for an enumerated type. The values of
+// flags are bits that a list of names ORs together.
+type enum struct {
+	names  []string
+	values map[string]reflect.Value
+	flags  bool
+}
+
+var (
+	enumsMu sync.RWMutex
+	enums   = make(map[reflect.Type]*enum)
+)
+
+// registerEnum records values, keyed by name, as the accepted values of typ.
+func registerEnum(typ reflect.Type, values map[string]reflect.Value, flags bool) {
+	e := &enum{values: values, flags: flags}
+	for name := range values {
+		e.names = append(e.names, name)
+	}
+	// list names in declaration order, as iota enums are usually written
+	sort.Slice(e.names, func(i, j int) bool {
+		vi, vj := values[e.names[i]], values[e.names[j]]
+		if vi.Kind() >= reflect.Uint && vi.Kind() <= reflect.Uintptr {
+			if vi.Uint() != vj.Uint() {
+				return vi.Uint() < vj.Uint()
+			}
+		} else if vi.Int() != vj.Int() {
+			return vi.Int() < vj.Int()
+		}
+		return e.names[i] < e.names[j]
+	})
+
+	enumsMu.Lock()
+	defer enumsMu.Unlock()
+	enums[typ] = e
+}
+
+// lookupEnum returns the enum registered for typ, or nil.
+func lookupEnum(typ reflect.Type) *enum {
+	enumsMu.RLock()
+	defer enumsMu.RUnlock()
+	return enums[typ]
+}
+
+// set assigns the value named value, compared case-insensitively, to field.
+// The value of flags is a list of names, split on the sep tag, whose values
+// are ORed together.
+func (e *enum) set(value string, field reflect.Value, tags reflect.StructTag) error {
+	if !e.flags {
+		name, err := matchOneOf(value, e.names, true)
+		if err != nil {
+			return err
+		}
+		field.Set(e.values[name])
+		return nil
+	}
+
+	sep, err := listSeparator(tags)
+	if err != nil {
+		return err
+	}
+	var bits uint64
+	if strings.TrimSpace(value) != "" {
+		for _, part := range strings.Split(value, sep) {
+			name, err := matchOneOf(strings.TrimSpace(part), e.names, true)
+			if err != nil {
+				return err
+			}
+			v := e.values[name]
+			if v.Kind() >= reflect.Uint && v.Kind() <= reflect.Uintptr {
+				bits |= v.Uint()
+			} else {
+				bits |= uint64(v.Int())
+			}
+		}
+	}
+	if field.Kind() >= reflect.Uint && field.Kind() <= reflect.Uintptr {
+		field.SetUint(bits)
+	} else {
+		field.SetInt(int64(bits))
+	}
+	return nil
+}
@@ -0,0 +1,42 @@
+// Copyright (c) 2016 Kelsey Hightower and others. All rights reserved.
+// Use of this source code is governed by the MIT License that can be found in
+// the LICENSE file.
+
+//go:build go1.18
+// +build go1.18
+
+package envconfig
+
+import "reflect"
+
+// An enumInteger is an integer type that enumerations are declared with.
+type enumInteger interface {
+	~int | ~int8 | ~int16 | ~int32 | ~int64 |
+		~uint | ~uint8 | ~uint16 | ~uint32 | ~uint64
+}
+
+// RegisterEnum registers the names of the values of an enumerated type, such
+// as one declared with iota. Fields of type T are then parsed by name,
+// ignoring case, and any other value is an error listing the valid names.
+// RegisterEnum is safe for concurrent use.
+func RegisterEnum[T enumInteger](values map[string]T) {
+	typ := reflect.TypeOf((*T)(nil)).Elem()
+	rv := make(map[string]reflect.Value, len(values))
+	for name, v := range values {
+		rv[name] = reflect.ValueOf(v)
+	}
+	registerEnum(typ, rv, false)
+}
+
+// RegisterFlags registers the names of the bits of a flags type. Fields of
+// type T are then parsed from a list of names, ignoring case, whose values
+// are ORed together, so "read,write" yields Read|Write. Any other name is an
+// error listing the valid names. RegisterFlags is safe for concurrent use.
+func RegisterFlags[T enumInteger](names map[string]T) {
+	typ := reflect.TypeOf((*T)(nil)).Elem()
+	rv := make(map[string]reflect.Value, len(names))
+	for name, v := range names {
+		rv[name] = reflect.ValueOf(v)
+	}
+	registerEnum(typ, rv, true)
+}
@@ -0,0 +1,149 @@
+// Copyright (c) 2016 Kelsey Hightower and others. All rights reserved.
+// Use of this source code is governed by the MIT License that can be found in
+// the LICENSE file.
+
+//go:build go1.18
+// +build go1.18
+
+package envconfig
+
+import (
+	"bytes"
+	"os"
+	"testing"
+)
+
+type logLevel int
+
+const (
+	levelDebug logLevel = iota
+	levelInfo
+	levelWarn
+)
+
+func init() {
+	RegisterEnum(map[string]logLevel{
+		"debug": levelDebug,
+		"info":  levelInfo,
+		"warn":  levelWarn,
+	})
+}
+
+func TestRegisterEnum(t *testing.T) {
+	var s struct {
+		Level    logLevel
+		LevelPtr *logLevel
+		Levels   []logLevel
+	}
+	os.Clearenv()
+	os.Setenv("ENV_CONFIG_LEVEL", "WARN")
+	os.Setenv("ENV_CONFIG_LEVELPTR", "info")
+	os.Setenv("ENV_CONFIG_LEVELS", "debug,Info")
+	if err := Process("env_config", &s); err != nil {
+		t.Fatal(err.Error())
+	}
+	if s.Level != levelWarn {
+		t.Errorf("expected %d, got %d", levelWarn, s.Level)
+	}
+	if s.LevelPtr == nil || *s.LevelPtr != levelInfo {
+		t.Errorf("expected %d, got %v", levelInfo, s.LevelPtr)
+	}
+	if len(s.Levels) != 2 || s.Levels[0] != levelDebug || s.Levels[1] != levelInfo {
+		t.Errorf("expected %v, got %v", []logLevel{levelDebug, levelInfo}, s.Levels)
+	}
+}
+
+func TestRegisterEnumError(t *testing.T) {
+	var s struct {
+		Level logLevel
+	}
+	os.Clearenv()
+	os.Setenv("ENV_CONFIG_LEVEL", "verbose")
+	err := Process("env_config", &s)
+	v, ok := err.(*ParseError)
+	if !ok {
+		t.Fatalf("expected ParseError, got %T %v", err, err)
+	}
+	if experr := `value "verbose" is not one of debug, info, warn`; v.Err.Error() != experr {
+		t.Errorf("expected %s, got %v", experr, v.Err)
+	}
+}
+
+func TestUsageEnumType(t *testing.T) {
+	var s struct {
+		Level logLevel
+	}
+	buf := new(bytes.Buffer)
+	if err := Usagef("env_config", &s, buf, "{{range .}}{{usage_key .}}={{usage_type .}}\n{{end}}"); err != nil {
+		t.Error(err.Error())
+	}
+	compareUsage("ENV_CONFIG_LEVEL=logLevel.(debug|info|warn)\n", buf.String(), t)
+}
+
+type permission uint8
+
+const (
+	permRead permission = 1 << iota
+	permWrite
+	permExec
+)
+
+func init() {
+	RegisterFlags(map[string]permission{
+		"read":  permRead,
+		"write": permWrite,
+		"exec":  permExec,
+	})
+}
+
+func TestRegisterFlags(t *testing.T) {
+	var s struct {
+		Single   permission
+		Multiple permission
+		Piped    permission `sep:"|"`
+		None     permission
+	}
+	os.Clearenv()
+	os.Setenv("ENV_CONFIG_SINGLE", "read")
+	os.Setenv("ENV_CONFIG_MULTIPLE", "read, WRITE")
+	os.Setenv("ENV_CONFIG_PIPED", "write|exec")
+	os.Setenv("ENV_CONFIG_NONE", "")
+	if err := Process("env_config", &s); err != nil {
+		t.Fatal(err.Error())
+	}
+	if s.Single != permRead {
+		t.Errorf("expected %d, got %d", permRead, s.Single)
+	}
+	if want := permRead | permWrite; s.Multiple != want {
+		t.Errorf("expected %d, got %d", want, s.Multiple)
+	}
+	if want := permWrite | permExec; s.Piped != want {
+		t.Errorf("expected %d, got %d", want, s.Piped)
+	}
+	if s.None != 0 {
+		t.Errorf("expected %d, got %d", 0, s.None)
+	}
+
+	os.Setenv("ENV_CONFIG_MULTIPLE", "read,delete")
+	err := Process("env_config", &s)
+	v, ok := err.(*ParseError)
+	if !ok {
+		t.Fatalf("expected ParseError, got %T %v", err, err)
+	}
+	if experr := `value "delete" is not one of read, write, exec`; v.Err.Error() != experr {
+		t.Errorf("expected %s, got %v", experr, v.Err)
+	}
+}
+
+func TestUsageFlagsType(t *testing.T) {
+	var s struct {
+		Perms permission
+	}
+	buf := new(bytes.Buffer)
+	if err := Usagef("env_config", &s, buf, "{{range .}}{{usage_type .}}\n{{end}}"); err != nil {
+		t.Error(err.Error())
+	}
+	if want := "Comma-separated permission flags (read|write|exec)\n"; buf.String() != want {
+		t.Errorf("expected %q, got %q", want, buf.String())
+	}
+}
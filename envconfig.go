@@ -6,16 +6,31 @@ package envconfig
 
 import (
 	"encoding"
+	"encoding/base32"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
+	"io/ioutil"
+	"math"
+	"net"
+	"net/url"
 	"os"
 	"reflect"
 	"regexp"
+	"sort"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 )
 
+// runeType is the element type of []rune fields tagged runes:"true", which
+// are assigned the runes of their value rather than a comma-separated list.
+var runeType = reflect.TypeOf(rune(0))
+
 // ErrInvalidSpecification indicates that a specification is of the wrong type.
 var ErrInvalidSpecification = errors.New("specification must be a struct pointer")
 
@@ -27,9 +42,15 @@ var acronymRegexp = regexp.MustCompile("([A-Z]+)([A-Z][^A-Z]+)")
 type ParseError struct {
 	KeyName   string
 	FieldName string
+	// FieldPath locates the field from the root of the specification, such
+	// as Database.Replicas[1].Host.
+	FieldPath string
 	TypeName  string
 	Value     string
 	Err       error
+	// Redacted is set for fields tagged secret:"true"; Error then hides the
+	// value.
+	Redacted bool
 }
 
 // Decoder has the same semantics as Setter, but takes higher precedence.
@@ -44,21 +65,138 @@ type Setter interface {
 	Set(value string) error
 }
 
+// ElementDecoder is implemented by collection types that decode a list one
+// element at a time, such as sets. The value is split as a slice would be,
+// honoring the sep tag, and AppendElement is called once per element on a
+// zero value of the type.
+type ElementDecoder interface {
+	AppendElement(value string) error
+}
+
 func (e *ParseError) Error() string {
-	return fmt.Sprintf("envconfig.Process: assigning %[1]s to %[2]s: converting '%[3]s' to type %[4]s. details: %[5]s", e.KeyName, e.FieldName, e.Value, e.TypeName, e.Err)
+	value, details := e.Value, fmt.Sprint(e.Err)
+	if e.Redacted {
+		// conversion errors often quote the value too
+		if value != "" {
+			details = strings.Replace(details, value, redacted, -1)
+		}
+		value = redacted
+	}
+	return fmt.Sprintf("envconfig.Process: assigning %[1]s to %[2]s: converting '%[3]s' to type %[4]s. details: %[5]s", e.KeyName, e.FieldName, value, e.TypeName, details)
+}
+
+// Unwrap returns the underlying conversion error.
+func (e *ParseError) Unwrap() error {
+	return e.Err
+}
+
+// Errors is returned by ProcessAll with the error of every field that failed,
+// in the order the fields were processed.
+type Errors struct {
+	Errs []error
+	// Truncated is set when processing stopped at the limit passed to
+	// WithMaxErrors, so more fields may have failed.
+	Truncated bool
+}
+
+func (e *Errors) Error() string {
+	msgs := make([]string, len(e.Errs))
+	for i, err := range e.Errs {
+		msgs[i] = err.Error()
+	}
+	msg := strings.Join(msgs, "; ")
+	if len(e.Errs) > 1 {
+		msg = fmt.Sprintf("%d errors: %s", len(e.Errs), msg)
+	}
+	if e.Truncated {
+		msg += fmt.Sprintf(" (stopped after %d errors)", len(e.Errs))
+	}
+	return msg
+}
+
+// Unwrap returns the collected errors, so that errors.Is and errors.As find
+// a ParseError among them.
+func (e *Errors) Unwrap() []error {
+	return e.Errs
+}
+
+// errMaxErrors stops ProcessAll once the limit of WithMaxErrors is reached.
+var errMaxErrors = errors.New("envconfig: too many errors")
+
+// A rangeError reports a value that does not fit in the native type of the
+// field it was assigned to.
+type rangeError struct {
+	value string
+	kind  reflect.Kind
+}
+
+func (e *rangeError) Error() string {
+	return fmt.Sprintf("value %s out of range for %s", e.value, e.kind)
+}
+
+// Unwrap returns strconv.ErrRange so callers can test for it with errors.Is.
+func (e *rangeError) Unwrap() error {
+	return strconv.ErrRange
+}
+
+// checkRange replaces a strconv range error with one that names the field's
+// type; any other error is returned unchanged.
+func checkRange(err error, value string, typ reflect.Type) error {
+	if errors.Is(err, strconv.ErrRange) {
+		return &rangeError{value: value, kind: typ.Kind()}
+	}
+	return err
+}
+
+// VarInfo maintains information about the configuration variable. It is the
+// data that usage templates are executed with.
+type VarInfo struct {
+	Name       string
+	Path       string
+	Alt        string
+	Key        string
+	Field      reflect.Value
+	Tags       reflect.StructTag
+	Deprecated string
+	// Fallbacks holds the keys under any fallback prefixes, tried in order
+	// after Key.
+	Fallbacks  []string
+	Remainder  bool
+	SplitWords bool
+	Indexed    bool
+}
+
+// parseTag splits an envconfig struct tag into the alternate name and the
+// comma-separated options that follow it.
+func parseTag(tag string) (string, []string) {
+	parts := strings.Split(tag, ",")
+	return parts[0], parts[1:]
+}
+
+func hasOption(opts []string, name string) bool {
+	for _, opt := range opts {
+		if strings.TrimSpace(opt) == name {
+			return true
+		}
+	}
+	return false
 }
 
-// varInfo maintains information about the configuration variable
-type varInfo struct {
-	Name  string
-	Alt   string
-	Key   string
-	Field reflect.Value
-	Tags  reflect.StructTag
+// normalizePrefix upper cases prefix and strips a single trailing
+// underscore, so that "app_" and "APP" both yield keys such as APP_PORT.
+func normalizePrefix(prefix string) string {
+	return strings.TrimSuffix(strings.ToUpper(prefix), "_")
 }
 
 // GatherInfo gathers information about the specified struct
-func gatherInfo(prefix string, spec interface{}) ([]varInfo, error) {
+func gatherInfo(prefix string, spec interface{}) ([]VarInfo, error) {
+	return gatherNested(prefix, spec, nil)
+}
+
+// gatherNested gathers information about the specified struct, nested in
+// structs of the types in parents. A struct that contains its own type
+// would otherwise be gathered forever, allocating each nil pointer to it.
+func gatherNested(prefix string, spec interface{}, parents []reflect.Type) ([]VarInfo, error) {
 	s := reflect.ValueOf(spec)
 
 	if s.Kind() != reflect.Ptr {
@@ -69,9 +207,10 @@ func gatherInfo(prefix string, spec interface{}) ([]varInfo, error) {
 		return nil, ErrInvalidSpecification
 	}
 	typeOfSpec := s.Type()
+	parents = append(parents[:len(parents):len(parents)], typeOfSpec)
 
 	// over allocate an info array, we will extend if needed later
-	infos := make([]varInfo, 0, s.NumField())
+	infos := make([]VarInfo, 0, s.NumField())
 	for i := 0; i < s.NumField(); i++ {
 		f := s.Field(i)
 		ftype := typeOfSpec.Field(i)
@@ -80,6 +219,10 @@ func gatherInfo(prefix string, spec interface{}) ([]varInfo, error) {
 		}
 
 		for f.Kind() == reflect.Ptr {
+			if isKnownType(f.Type().Elem()) {
+				// processField replaces the whole pointer
+				break
+			}
 			if f.IsNil() {
 				if f.Type().Elem().Kind() != reflect.Struct {
 					// nil pointer to a non-struct: leave it alone
@@ -92,11 +235,29 @@ func gatherInfo(prefix string, spec interface{}) ([]varInfo, error) {
 		}
 
 		// Capture information about the config variable
-		info := varInfo{
-			Name:  ftype.Name,
-			Field: f,
-			Tags:  ftype.Tag,
-			Alt:   strings.ToUpper(ftype.Tag.Get("envconfig")),
+		alt, opts := parseTag(ftype.Tag.Get("envconfig"))
+		info := VarInfo{
+			Name:       ftype.Name,
+			Path:       ftype.Name,
+			Field:      f,
+			Tags:       ftype.Tag,
+			Alt:        strings.ToUpper(alt),
+			Deprecated: strings.ToUpper(ftype.Tag.Get("deprecated_name")),
+			Remainder:  hasOption(opts, "remainder"),
+		}
+
+		if info.Remainder {
+			if f.Kind() != reflect.Map || f.Type().Key().Kind() != reflect.String {
+				return nil, fmt.Errorf("envconfig: remainder field %s must be a map with string keys", info.Name)
+			}
+			// The remainder field has no key of its own; it receives every
+			// variable under the prefix that no other field claims.
+			info.Key = "*"
+			if prefix != "" {
+				info.Key = strings.ToUpper(prefix) + "_*"
+			}
+			infos = append(infos, info)
+			continue
 		}
 
 		// Default to the field name as the env var name (will be upcased)
@@ -116,30 +277,72 @@ func gatherInfo(prefix string, spec interface{}) ([]varInfo, error) {
 				}
 
 				info.Key = strings.Join(name, "_")
+				info.SplitWords = true
 			}
 		}
 		if info.Alt != "" {
 			info.Key = info.Alt
+			info.SplitWords = false
 		}
 		if prefix != "" {
 			info.Key = fmt.Sprintf("%s_%s", prefix, info.Key)
 		}
 		info.Key = strings.ToUpper(info.Key)
+		if hasOption(opts, "json") || ftype.Tag.Get("format") == "json" || f.Type() == rawMessageType {
+			// the same as the json tag, which processField looks for
+			info.Tags = withTag(info.Tags, "json", "true")
+		}
+		if err := checkProviders(ftype.Tag.Get("default")); err != nil {
+			return nil, fmt.Errorf("envconfig: %v for %s", err, info.Name)
+		}
+		if tag := ftype.Tag.Get("transform"); tag != "" {
+			if _, err := parseTransforms(tag); err != nil {
+				return nil, fmt.Errorf("envconfig: %v for %s", err, info.Name)
+			}
+		}
+		if list := ftype.Tag.Get("sources"); list != "" {
+			if _, err := parseSources(list); err != nil {
+				return nil, fmt.Errorf("envconfig: %v for %s", err, info.Name)
+			}
+		}
+		if name := ftype.Tag.Get("impl"); name != "" {
+			impl, err := implValue(name, f)
+			if err != nil {
+				return nil, fmt.Errorf("envconfig: %v for %s", err, info.Name)
+			}
+			f = impl
+			info.Field = f
+		}
+		if name := ftype.Tag.Get("parser"); name != "" {
+			if _, ok := lookupParser(name); !ok {
+				return nil, fmt.Errorf("envconfig: unknown parser %q for %s", name, info.Name)
+			}
+		} else {
+			info.Indexed = isStructSlice(f.Type()) || isIndexedMap(f.Type(), ftype.Tag)
+		}
 		infos = append(infos, info)
 
-		if f.Kind() == reflect.Struct {
+		if f.Kind() == reflect.Struct && !isTrue(info.Tags.Get("json")) && ftype.Tag.Get("parser") == "" {
 			// honor Decode if present
-			if decoderFrom(f) == nil && setterFrom(f) == nil && textUnmarshaler(f) == nil && binaryUnmarshaler(f) == nil {
+			if decoderFrom(f) == nil && setterFrom(f) == nil && textUnmarshaler(f) == nil && binaryUnmarshaler(f) == nil && !isKnownType(f.Type()) {
 				innerPrefix := prefix
 				if !ftype.Anonymous {
 					innerPrefix = info.Key
 				}
 
 				embeddedPtr := f.Addr().Interface()
-				embeddedInfos, err := gatherInfo(innerPrefix, embeddedPtr)
+				for _, parent := range parents {
+					if f.Type() == parent {
+						return nil, fmt.Errorf("envconfig: type %s is recursive through field %s", parent, info.Name)
+					}
+				}
+				embeddedInfos, err := gatherNested(innerPrefix, embeddedPtr, parents)
 				if err != nil {
 					return nil, err
 				}
+				for j := range embeddedInfos {
+					embeddedInfos[j].Path = info.Path + "." + embeddedInfos[j].Path
+				}
 				infos = append(infos[:len(infos)-1], embeddedInfos...)
 
 				continue
@@ -152,207 +355,1998 @@ func gatherInfo(prefix string, spec interface{}) ([]varInfo, error) {
 // CheckDisallowed checks that no environment variables with the prefix are set
 // that we don't know how or want to parse. This is likely only meaningful with
 // a non-empty prefix.
-func CheckDisallowed(prefix string, spec interface{}) error {
-	infos, err := gatherInfo(prefix, spec)
+func CheckDisallowed(prefix string, spec interface{}, opts ...Option) error {
+	unknown, err := disallowedKeys(prefix, spec, newOptions(opts))
 	if err != nil {
 		return err
 	}
+	if len(unknown) > 0 {
+		return fmt.Errorf("unknown environment variable %s", unknown[0])
+	}
+	return nil
+}
 
-	vars := make(map[string]struct{})
-	for _, info := range infos {
-		vars[info.Key] = struct{}{}
+// CheckDisallowedWarn is like CheckDisallowed, but logs a warning for each
+// unknown variable instead of failing, for environments where the platform
+// sets variables of its own under the prefix. The warnings go to the logger
+// given with WithLogger, or to the standard logger. The unknown variables
+// are returned, and the error is only set when spec cannot be processed.
+func CheckDisallowedWarn(prefix string, spec interface{}, opts ...Option) ([]string, error) {
+	o := newOptions(opts)
+	unknown, err := disallowedKeys(prefix, spec, o)
+	if err != nil {
+		return nil, err
+	}
+	for _, key := range unknown {
+		o.warnf("envconfig: unknown environment variable %s", key)
 	}
+	return unknown, nil
+}
 
-	if prefix != "" {
-		prefix = strings.ToUpper(prefix) + "_"
+// disallowedKeys returns the variables with the prefix that spec does not
+// read.
+func disallowedKeys(prefix string, spec interface{}, o *options) ([]string, error) {
+	prefix = normalizePrefix(prefix)
+	infos, err := gatherInfoWith(prefix, spec, o)
+	if err != nil {
+		return nil, err
 	}
 
-	for _, env := range os.Environ() {
-		if !strings.HasPrefix(env, prefix) {
-			continue
+	rem, err := remainderInfo(infos)
+	if err != nil {
+		return nil, err
+	}
+	if rem != nil {
+		// every prefixed variable is captured by the remainder field
+		return nil, nil
+	}
+
+	infos, err = expandIndexed(infos, o.env)
+	if err != nil {
+		return nil, err
+	}
+	var unknown []string
+	for _, key := range unusedKeys(o.prefixes(prefix), infos, o.env) {
+		if key != o.requiredVar {
+			unknown = append(unknown, key)
 		}
-		v := strings.SplitN(env, "=", 2)[0]
-		if _, found := vars[v]; !found {
-			return fmt.Errorf("unknown environment variable %s", v)
+	}
+	return unknown, nil
+}
+
+// unusedKeys returns the names of the environment variables under any of
+// prefixes that none of infos read.
+func unusedKeys(prefixes []string, infos []VarInfo, env environment) []string {
+	vars := claimedKeys(infos)
+
+	var unused []string
+	for _, kv := range env.environ() {
+		v := strings.SplitN(kv, "=", 2)[0]
+		if _, found := vars[v]; found || !hasAnyPrefix(v, prefixes) {
+			continue
 		}
+		unused = append(unused, v)
 	}
+	return unused
+}
 
-	return nil
+// hasAnyPrefix reports whether key is under any of prefixes.
+func hasAnyPrefix(key string, prefixes []string) bool {
+	for _, prefix := range prefixes {
+		if prefix == "" || strings.HasPrefix(key, strings.ToUpper(prefix)+"_") {
+			return true
+		}
+	}
+	return false
 }
 
-// Process populates the specified struct based on environment variables
-func Process(prefix string, spec interface{}) error {
-	infos, err := gatherInfo(prefix, spec)
+// KnownKeys returns every environment variable name that the specified
+// struct may be read from, sorted: keys, alternate and deprecated names and
+// the keys under fallback prefixes. As the elements of an indexed slice may
+// be read from any index, their names are templated with N in place of the
+// index, such as MYAPP_SERVERS_N_HOST, and the remainder field appears as
+// MYAPP_*. It suits building allowlists of variables to pass through.
+func KnownKeys(prefix string, spec interface{}, opts ...Option) ([]string, error) {
+	prefix = normalizePrefix(prefix)
+	o := newOptions(opts)
+	s := reflect.ValueOf(spec)
+	if s.Kind() != reflect.Ptr || s.Elem().Kind() != reflect.Struct {
+		return nil, ErrInvalidSpecification
+	}
+	// gather from a scratch copy, as gatherInfo allocates nil pointers
+	infos, err := gatherInfoWith(prefix, reflect.New(s.Elem().Type()).Interface(), o)
+	if err != nil {
+		return nil, err
+	}
 
-	for _, info := range infos {
+	known := make(map[string]struct{})
+	if err := addKnownKeys(known, infos, []reflect.Type{s.Elem().Type()}); err != nil {
+		return nil, err
+	}
+	keys := make([]string, 0, len(known))
+	for key := range known {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys, nil
+}
 
-		// `os.Getenv` cannot differentiate between an explicitly set empty value
-		// and an unset value. `os.LookupEnv` is preferred to `syscall.Getenv`,
-		// but it is only available in go1.5 or newer. We're using Go build tags
-		// here to use os.LookupEnv for >=go1.5
-		value, ok := lookupEnv(info.Key)
-		if !ok && info.Alt != "" {
-			value, ok = lookupEnv(info.Alt)
+// addKnownKeys adds the names read by infos to known, templating those of
+// indexed slice elements. parents holds the types the infos are nested in.
+func addKnownKeys(known map[string]struct{}, infos []VarInfo, parents []reflect.Type) error {
+	for key := range claimedKeys(infos) {
+		known[key] = struct{}{}
+	}
+	for _, info := range infos {
+		if info.Remainder {
+			known[info.Key] = struct{}{}
+		}
+		if !info.Indexed {
+			continue
+		}
+		elem := info.Field.Type().Elem()
+		if elem.Kind() == reflect.Ptr {
+			elem = elem.Elem()
+		}
+		if !isPlainStruct(elem) {
+			// the scalar values of an indexed map
+			known[info.Key+"_N"] = struct{}{}
+			continue
+		}
+		for _, parent := range parents {
+			if elem == parent {
+				// the templated names would nest forever
+				return fmt.Errorf("envconfig: type %s is recursive through field %s", parent, info.Name)
+			}
+		}
+		elemInfos, err := gatherInfo(info.Key+"_N", reflect.New(elem).Interface())
+		if err != nil {
+			return err
 		}
+		for j := range elemInfos {
+			// elements are only read from their prefixed keys
+			elemInfos[j].Alt = ""
+			elemInfos[j].Deprecated = ""
+		}
+		if err := addKnownKeys(known, elemInfos, append(parents[:len(parents):len(parents)], elem)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
 
-		def := info.Tags.Get("default")
-		if def != "" && !ok {
-			value = def
+// claimedKeys returns the set of environment variable names read by infos.
+func claimedKeys(infos []VarInfo) map[string]struct{} {
+	vars := make(map[string]struct{})
+	for _, info := range infos {
+		if info.Remainder {
+			continue
+		}
+		vars[info.Key] = struct{}{}
+		if info.Alt != "" {
+			vars[info.Alt] = struct{}{}
 		}
+		if info.Deprecated != "" {
+			vars[info.Deprecated] = struct{}{}
+		}
+		for _, key := range info.Fallbacks {
+			vars[key] = struct{}{}
+		}
+	}
+	return vars
+}
 
-		req := info.Tags.Get("required")
-		if !ok && def == "" {
-			if isTrue(req) {
-				key := info.Key
-				if info.Alt != "" {
-					key = info.Alt
-				}
-				return fmt.Errorf("required key %s missing value", key)
-			}
+// remainderInfo returns the field tagged as the remainder, if any. A
+// specification may have at most one.
+func remainderInfo(infos []VarInfo) (*VarInfo, error) {
+	var rem *VarInfo
+	for i := range infos {
+		if !infos[i].Remainder {
 			continue
 		}
+		if rem != nil {
+			return nil, fmt.Errorf("envconfig: multiple remainder fields: %s and %s", rem.Name, infos[i].Name)
+		}
+		rem = &infos[i]
+	}
+	return rem, nil
+}
 
-		err = processField(value, info.Field)
-		if err != nil {
+// processRemainder assigns every variable under prefix that is not claimed by
+// another field to the remainder field, keyed by the name without the prefix.
+func processRemainder(prefix string, rem *VarInfo, infos []VarInfo, env environment) error {
+	vars := claimedKeys(infos)
+
+	if prefix != "" {
+		prefix = strings.ToUpper(prefix) + "_"
+	}
+
+	typ := rem.Field.Type()
+	mp := reflect.MakeMap(typ)
+	for _, kv := range env.environ() {
+		if !strings.HasPrefix(kv, prefix) {
+			continue
+		}
+		key := strings.SplitN(kv, "=", 2)[0]
+		if _, found := vars[key]; found {
+			continue
+		}
+		// look the value up, rather than splitting kv, so that it is
+		// recorded like any other variable read
+		value, ok := env.lookup(key)
+		if !ok {
+			continue
+		}
+		v := reflect.New(typ.Elem()).Elem()
+		if err := processField(value, v, rem.Tags); err != nil {
 			return &ParseError{
-				KeyName:   info.Key,
-				FieldName: info.Name,
-				TypeName:  info.Field.Type().String(),
+				KeyName:   key,
+				FieldName: rem.Name,
+				FieldPath: rem.Path,
+				TypeName:  typ.Elem().String(),
 				Value:     value,
 				Err:       err,
 			}
 		}
+		k := reflect.ValueOf(strings.TrimPrefix(key, prefix)).Convert(typ.Key())
+		mp.SetMapIndex(k, v)
 	}
+	rem.Field.Set(mp)
+	return nil
+}
 
+// Process populates the specified struct based on environment variables.
+//
+// A field is assigned from its prefixed key or, failing that, its unprefixed
+// envconfig tag name. If neither is present in the environment the default
+// tag is used instead; a variable that is set to the empty string counts as
+// present, so its default is not applied. Fields with no value and no default
+// are left untouched. See WithoutDefaults to disable defaults altogether.
+func Process(prefix string, spec interface{}, opts ...Option) error {
+	_, err := process(normalizePrefix(prefix), spec, newOptions(opts))
 	return err
 }
 
-// MustProcess is the same as Process but panics if an error occurs
-func MustProcess(prefix string, spec interface{}) {
-	if err := Process(prefix, spec); err != nil {
-		panic(err)
+// ProcessAll is like Process, but rather than stopping at the first field
+// that fails to parse or is required but unset, it processes every field and
+// returns all of their errors as an *Errors. Errors that concern the
+// specification itself are returned as they are. WithMaxErrors limits how
+// many errors are collected; by default there is no limit. An onError
+// callback still runs first, and the errors it suppresses are not collected.
+func ProcessAll(prefix string, spec interface{}, opts ...Option) error {
+	o := newOptions(opts)
+	collected := &Errors{}
+	onError, onComplete := o.onError, o.onComplete
+	o.onError = func(info VarInfo, err error) error {
+		if onError != nil {
+			if err = onError(info, err); err == nil {
+				return nil
+			}
+		}
+		if o.maxErrors > 0 && len(collected.Errs) == o.maxErrors {
+			collected.Truncated = true
+			return errMaxErrors
+		}
+		collected.Errs = append(collected.Errs, err)
+		return nil
+	}
+	if onComplete != nil {
+		o.onComplete = func(values map[string]string) {
+			// processing only succeeded if nothing was collected
+			if len(collected.Errs) == 0 {
+				onComplete(values)
+			}
+		}
+	}
+
+	// once truncated, whatever error stopped processing stems from the limit
+	_, err := process(normalizePrefix(prefix), spec, o)
+	if collected.Truncated || err == nil && len(collected.Errs) > 0 {
+		return collected
 	}
+	return err
 }
 
-func processField(value string, field reflect.Value) error {
-	typ := field.Type()
+// ProcessStrict is like Process followed by CheckDisallowed: it also fails
+// when a variable under the prefix is set that the specification does not
+// read, such as a misspelled ENV_CONFIG_PROT. Every unknown variable is
+// reported, together with any error from processing, as an *Errors when
+// there is more than one. The spec is still populated from the variables
+// that are known.
+func ProcessStrict(prefix string, spec interface{}, opts ...Option) error {
+	err := Process(prefix, spec, opts...)
+	if err == ErrInvalidSpecification {
+		return err
+	}
+	unknown, uerr := disallowedKeys(prefix, spec, newOptions(opts))
+	if uerr != nil {
+		return uerr
+	}
 
-	decoder := decoderFrom(field)
-	if decoder != nil {
-		return decoder.Decode(value)
+	var errs []error
+	if err != nil {
+		errs = append(errs, err)
 	}
-	// look for Set method if Decode not defined
-	setter := setterFrom(field)
-	if setter != nil {
-		return setter.Set(value)
+	for _, key := range unknown {
+		errs = append(errs, fmt.Errorf("unknown environment variable %s", key))
+	}
+	switch len(errs) {
+	case 0:
+		return nil
+	case 1:
+		return errs[0]
 	}
+	return &Errors{Errs: errs}
+}
 
-	if t := textUnmarshaler(field); t != nil {
-		return t.UnmarshalText([]byte(value))
+// process implements Process. It returns the variables of spec, including
+// those of indexed slice elements when processing succeeds.
+func process(prefix string, spec interface{}, o *options) ([]VarInfo, error) {
+	infos, err := gatherInfoWith(prefix, spec, o)
+	if err != nil {
+		return nil, err
+	}
+	if err := requireListed(prefix, infos, o); err != nil {
+		return infos, err
 	}
 
-	if b := binaryUnmarshaler(field); b != nil {
-		return b.UnmarshalBinary([]byte(value))
+	rem, err := remainderInfo(infos)
+	if err != nil {
+		return infos, err
 	}
 
-	if typ.Kind() == reflect.Ptr {
-		typ = typ.Elem()
-		if field.IsNil() {
-			field.Set(reflect.New(typ))
-		}
-		field = field.Elem()
+	defaults := defaultValues(spec, o)
+	o.registered = registeredDefaultsFor(spec)
+
+	processed, err := processInfos(infos, o, defaults)
+	if err != nil {
+		return infos, err
 	}
 
-	switch typ.Kind() {
-	case reflect.String:
-		field.SetString(value)
-	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
-		var (
-			val int64
-			err error
-		)
-		if field.Kind() == reflect.Int64 && typ.PkgPath() == "time" && typ.Name() == "Duration" {
-			var d time.Duration
-			d, err = time.ParseDuration(value)
-			val = int64(d)
-		} else {
-			val, err = strconv.ParseInt(value, 0, typ.Bits())
-		}
-		if err != nil {
-			return err
+	if rem != nil {
+		if err := processRemainder(prefix, rem, processed, o.env); err != nil {
+			return processed, err
 		}
+	}
 
-		field.SetInt(val)
-	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
-		val, err := strconv.ParseUint(value, 0, typ.Bits())
-		if err != nil {
-			return err
-		}
-		field.SetUint(val)
-	case reflect.Bool:
-		val, err := strconv.ParseBool(value)
+	if c, ok := spec.(Computer); ok {
+		c.SetComputed()
+	}
+
+	if o.onComplete != nil {
+		values, err := EffectiveConfig(prefix, spec)
 		if err != nil {
-			return err
+			return processed, err
 		}
-		field.SetBool(val)
-	case reflect.Float32, reflect.Float64:
-		val, err := strconv.ParseFloat(value, typ.Bits())
-		if err != nil {
-			return err
+		o.onComplete(values)
+	}
+
+	return processed, nil
+}
+
+// requireListed tags the variables named by the WithRequiredVar variable as
+// required.
+func requireListed(prefix string, infos []VarInfo, o *options) error {
+	if o.requiredVar == "" {
+		return nil
+	}
+	list, _ := o.env.lookup(o.requiredVar)
+	for _, name := range strings.Split(list, ",") {
+		name = strings.ToUpper(strings.TrimSpace(name))
+		if name == "" {
+			continue
 		}
-		field.SetFloat(val)
-	case reflect.Slice:
-		sl := reflect.MakeSlice(typ, 0, 0)
-		if typ.Elem().Kind() == reflect.Uint8 {
-			sl = reflect.ValueOf([]byte(value))
-		} else if strings.TrimSpace(value) != "" {
-			vals := strings.Split(value, ",")
-			sl = reflect.MakeSlice(typ, len(vals), len(vals))
-			for i, val := range vals {
-				err := processField(val, sl.Index(i))
-				if err != nil {
-					return err
-				}
+		found := false
+		for i, info := range infos {
+			if info.Key == name || prefix != "" && info.Key == prefix+"_"+name {
+				infos[i].Tags = withTag(info.Tags, "required", "true")
+				found = true
 			}
 		}
-		field.Set(sl)
-	case reflect.Map:
-		mp := reflect.MakeMap(typ)
-		if strings.TrimSpace(value) != "" {
-			pairs := strings.Split(value, ",")
-			for _, pair := range pairs {
-				kvpair := strings.Split(pair, ":")
-				if len(kvpair) != 2 {
-					return fmt.Errorf("invalid map item: %q", pair)
-				}
-				k := reflect.New(typ.Key()).Elem()
-				err := processField(kvpair[0], k)
-				if err != nil {
-					return err
-				}
-				v := reflect.New(typ.Elem()).Elem()
-				err = processField(kvpair[1], v)
-				if err != nil {
-					return err
-				}
-				mp.SetMapIndex(k, v)
-			}
+		if !found {
+			return fmt.Errorf("envconfig: %s lists unknown variable %s", o.requiredVar, name)
 		}
-		field.Set(mp)
 	}
-
 	return nil
 }
 
-func interfaceFrom(field reflect.Value, fn func(interface{}, *bool)) {
-	// it may be impossible for a struct field to fail this check
-	if !field.CanInterface() {
-		return
-	}
-	var ok bool
-	fn(field.Interface(), &ok)
-	if !ok && field.CanAddr() {
-		fn(field.Addr().Interface(), &ok)
+// ProcessWithSnapshot is the same as Process but also returns the variables
+// that were read, keyed by name, to record the input a configuration was
+// built from. This includes variables read through alternate names and those
+// referenced by defaults. The values of fields tagged secret:"true" are
+// replaced by "****". The snapshot is returned even if processing fails.
+func ProcessWithSnapshot(prefix string, spec interface{}, opts ...Option) (map[string]string, error) {
+	o := newOptions(opts)
+	snapshot := make(map[string]string)
+	// secrets holds the variables read while resolving a secret field,
+	// including those its default refers to
+	secrets := make(map[string]bool)
+	secret := false
+	o.onResolve = func(info VarInfo) func() {
+		secret = isTrue(info.Tags.Get("secret"))
+		return func() { secret = false }
+	}
+	env := o.env
+	o.env.lookup = func(key string) (string, bool) {
+		value, ok := env.lookup(key)
+		if ok {
+			snapshot[key] = value
+			if secret {
+				secrets[key] = true
+			}
+		}
+		return value, ok
+	}
+
+	_, err := process(normalizePrefix(prefix), spec, o)
+	for key := range secrets {
+		snapshot[key] = redacted
+	}
+	return snapshot, err
+}
+
+// ProcessReport is the same as Process but also reports where the value of
+// each variable came from, so that a service can log which fields fell back
+// to their defaults. Defaults supplied by a DefaultValues method are reported
+// as SourceDefault with an empty Value. Only Key, FieldName, Value and Source
+// are set; a failure is returned as the error, along with the results
+// collected until then.
+func ProcessReport(prefix string, spec interface{}, opts ...Option) ([]FieldResult, error) {
+	o := newOptions(opts)
+	var results []FieldResult
+	o.results = &results
+	_, err := process(normalizePrefix(prefix), spec, o)
+	return results, err
+}
+
+// A Computer derives fields from others, such as a URL from a scheme and a
+// host. Process calls SetComputed after every variable and default has been
+// assigned, so derived fields are always consistent with the values loaded.
+type Computer interface {
+	SetComputed()
+}
+
+// ProcessPrefixes populates the specified struct like Process, trying each of
+// prefixes in order for every field until a value is found. It eases moving a
+// specification to a new prefix while still honoring the old one. Indexed
+// slice elements and the remainder field use the first prefix only.
+func ProcessPrefixes(spec interface{}, prefixes ...string) error {
+	if len(prefixes) == 0 {
+		return Process("", spec)
+	}
+	return Process(prefixes[0], spec, WithFallbackPrefixes(prefixes[1:]...))
+}
+
+// ProcessJSON populates the specified struct from a JSON object held in the
+// single environment variable envVar, decoded with encoding/json. Fields the
+// object leaves out keep their values, and nothing is assigned when envVar
+// is unset, so a subsequent call to Process can override individual fields.
+// A value that does not decode is reported as a *ParseError.
+func ProcessJSON(envVar string, spec interface{}) error {
+	s := reflect.ValueOf(spec)
+	if s.Kind() != reflect.Ptr || s.Elem().Kind() != reflect.Struct {
+		return ErrInvalidSpecification
+	}
+	value, ok := osEnv.lookup(envVar)
+	if !ok {
+		return nil
+	}
+	if err := json.Unmarshal([]byte(value), spec); err != nil {
+		return &ParseError{
+			KeyName:   envVar,
+			FieldName: s.Elem().Type().Name(),
+			TypeName:  s.Elem().Type().String(),
+			Value:     value,
+			Err:       err,
+		}
+	}
+	return nil
+}
+
+// gatherInfoWith gathers information about the specified struct, including
+// the keys of each variable under the fallback prefixes of o.
+func gatherInfoWith(prefix string, spec interface{}, o *options) ([]VarInfo, error) {
+	infos, err := gatherInfo(prefix, spec)
+	if err != nil {
+		return nil, err
+	}
+	if o.strictTags {
+		for _, info := range infos {
+			if err := checkTagKeys(info.Tags); err != nil {
+				return nil, fmt.Errorf("envconfig: %s: %v", info.Path, err)
+			}
+		}
+	}
+	for _, fallback := range o.fallbackPrefixes {
+		others, err := gatherInfo(normalizePrefix(fallback), spec)
+		if err != nil {
+			return nil, err
+		}
+		for i := range infos {
+			infos[i].Fallbacks = append(infos[i].Fallbacks, others[i].Key)
+		}
+	}
+	return infos, nil
+}
+
+// processInfos assigns values to the fields described by infos. It returns
+// infos extended with the variables of any indexed slice elements.
+func processInfos(infos []VarInfo, o *options, defaults map[string]interface{}) ([]VarInfo, error) {
+	processed := infos
+	var missing []VarInfo
+	unset := make(map[string]bool)
+	for _, info := range infos {
+		if info.Remainder {
+			continue
+		}
+		info.Tags = o.tags(info)
+
+		value, src := resolve(info, o)
+		o.logVar(info, value, src)
+		if src != SourceUnset || info.Indexed {
+			o.record(info, value, src)
+		}
+		if src == SourceDeprecated {
+			o.warnf("envconfig: %s is deprecated, use %s instead", info.Deprecated, info.Key)
+		}
+		if info.Indexed && info.Field.Kind() == reflect.Map {
+			elems, err := processIndexedMap(value, src, info, o, claimedKeys(infos))
+			if err != nil {
+				return nil, err
+			}
+			processed = append(processed, elems...)
+			continue
+		}
+		if info.Indexed {
+			elems, err := processIndexed(value, src, info, o)
+			if err != nil {
+				return nil, err
+			}
+			processed = append(processed, elems...)
+			continue
+		}
+
+		if src == SourceUnset {
+			assigned, err := assignDefaultValue(info, defaults)
+			if err != nil {
+				return nil, err
+			}
+			if assigned {
+				o.record(info, "", SourceDefault)
+			} else {
+				o.record(info, "", SourceUnset)
+			}
+			if !assigned && o.requireAll {
+				// the unset fields are reported together, unless the
+				// callback replaces the error
+				merr := missingError(info)
+				if err := o.fieldError(info, merr); err == merr {
+					missing = append(missing, info)
+				} else if err != nil {
+					return nil, err
+				}
+			} else if !assigned && isTrue(info.Tags.Get("required")) || assigned && o.requiresEnv(info) {
+				if err := o.fieldError(info, missingError(info)); err != nil {
+					return nil, err
+				}
+			} else if !assigned {
+				unset[info.Path] = true
+			}
+			continue
+		}
+		if src == SourceDefault && o.requiresEnv(info) {
+			// a suppressed error leaves the default as a fallback
+			if err := o.fieldError(info, missingError(info)); err != nil {
+				return nil, err
+			}
+		}
+
+		if err := processVar(value, info); err != nil {
+			if err = o.fieldError(info, err); err != nil {
+				return nil, err
+			}
+		}
+	}
+	// conditions are checked once every field, including the referenced
+	// ones, has been assigned
+	conditional, err := requiredIfMissing(infos, unset)
+	if err != nil {
+		return nil, err
+	}
+	for _, info := range conditional {
+		if err := o.fieldError(info, missingError(info)); err != nil {
+			return nil, err
+		}
+	}
+	if len(missing) > 0 {
+		return nil, missingKeysError(missing)
+	}
+	return processed, nil
+}
+
+// requiredIfMissing returns the variables of infos whose paths are in unset
+// and whose required_if tag refers to a bool field that is true.
+func requiredIfMissing(infos []VarInfo, unset map[string]bool) ([]VarInfo, error) {
+	var missing []VarInfo
+	for _, info := range infos {
+		if info.Tags.Get("required_if") == "" {
+			continue
+		}
+		target, err := requiredIfTarget(infos, info)
+		if err != nil {
+			return nil, err
+		}
+		if unset[info.Path] && boolValue(target.Field) {
+			missing = append(missing, info)
+		}
+	}
+	return missing, nil
+}
+
+// requiredIfTarget returns the bool field that the required_if tag of info
+// refers to, by its Go name among the fields of the same struct or by its
+// full path.
+func requiredIfTarget(infos []VarInfo, info VarInfo) (VarInfo, error) {
+	name := info.Tags.Get("required_if")
+	sibling := name
+	if i := strings.LastIndex(info.Path, "."); i >= 0 {
+		sibling = info.Path[:i+1] + name
+	}
+	for _, target := range infos {
+		if target.Path != sibling && target.Path != name {
+			continue
+		}
+		typ := target.Field.Type()
+		for typ.Kind() == reflect.Ptr {
+			typ = typ.Elem()
+		}
+		if typ.Kind() != reflect.Bool {
+			return VarInfo{}, fmt.Errorf("envconfig: required_if of %s refers to %s, which is not a bool", info.Path, name)
+		}
+		return target, nil
+	}
+	return VarInfo{}, fmt.Errorf("envconfig: required_if of %s refers to unknown field %s", info.Path, name)
+}
+
+// boolValue returns the value of a bool field, following pointers. A nil
+// pointer is false.
+func boolValue(field reflect.Value) bool {
+	for field.Kind() == reflect.Ptr {
+		if field.IsNil() {
+			return false
+		}
+		field = field.Elem()
+	}
+	return field.Bool()
+}
+
+// processIndexed populates a slice of structs. A json field is first loaded
+// in bulk from its own variable; indexed variables such as KEY_0_HOST then
+// override individual fields, growing the slice as needed.
+func processIndexed(value string, src Source, info VarInfo, o *options) ([]VarInfo, error) {
+	elemOpts := *o
+	// registered defaults are keyed by field paths of the outer spec, which
+	// do not reach into elements
+	elemOpts.registered = nil
+	if src != SourceUnset && isTrue(info.Tags.Get("json")) {
+		if err := processVar(value, info); err != nil {
+			if err = o.fieldError(info, err); err != nil {
+				return nil, err
+			}
+		}
+		// keep element defaults from overwriting the loaded values
+		elemOpts.noDefaults = true
+	}
+
+	if n := indexedLen(info.Key, o.env); n > info.Field.Len() {
+		grown := reflect.MakeSlice(info.Field.Type(), n, n)
+		reflect.Copy(grown, info.Field)
+		info.Field.Set(grown)
+	}
+
+	elems, err := elementInfos(info, info.Field)
+	if err != nil {
+		return nil, err
+	}
+	return processInfos(elems, &elemOpts, nil)
+}
+
+// isStructSlice reports whether t is a slice of nested specifications, which
+// are configured through indexed variables such as PREFIX_SERVERS_0_HOST.
+func isStructSlice(t reflect.Type) bool {
+	if t.Kind() != reflect.Slice {
+		return false
+	}
+	elem := t.Elem()
+	if elem.Kind() == reflect.Ptr {
+		elem = elem.Elem()
+	}
+	return isPlainStruct(elem)
+}
+
+// isPlainStruct reports whether t is a struct whose fields are read from
+// variables of their own, rather than one that decodes itself.
+func isPlainStruct(t reflect.Type) bool {
+	return t.Kind() == reflect.Struct && !implementsInterface(t) && !isKnownType(t)
+}
+
+// isIndexedMap reports whether t is a map with integer keys that is read from
+// indexed variables, such as KEY_5 or, for struct values, KEY_5_HOST. Maps
+// of structs always are; maps of other values when tagged indexed:"true".
+func isIndexedMap(t reflect.Type, tags reflect.StructTag) bool {
+	if t.Kind() != reflect.Map {
+		return false
+	}
+	switch t.Key().Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+	default:
+		return false
+	}
+	return isPlainStruct(mapValueType(t)) || isTrue(tags.Get("indexed"))
+}
+
+// mapValueType returns the value type of the map type t, dereferenced.
+func mapValueType(t reflect.Type) reflect.Type {
+	elem := t.Elem()
+	if elem.Kind() == reflect.Ptr {
+		elem = elem.Elem()
+	}
+	return elem
+}
+
+// mapIndices returns the indices N, in increasing order, for which a
+// variable named key_N is set or, when structs is set, a variable named
+// key_N_*. Variables in claimed belong to other fields, such as KEY_FILE for
+// a field next to one with key KEY. Any other variable under key_ is an
+// error, as the index must be an integer.
+func mapIndices(key string, structs bool, env environment, claimed map[string]struct{}) ([]string, error) {
+	key += "_"
+	seen := make(map[string]bool)
+	var indices []string
+	for _, kv := range env.environ() {
+		name := strings.SplitN(kv, "=", 2)[0]
+		if _, ok := claimed[name]; ok || !strings.HasPrefix(name, key) {
+			continue
+		}
+		index := name[len(key):]
+		if structs {
+			index = strings.SplitN(index, "_", 2)[0]
+		}
+		if _, err := strconv.ParseInt(index, 10, 64); err != nil {
+			return nil, fmt.Errorf("envconfig: %s is not indexed by an integer", name)
+		}
+		if !seen[index] {
+			seen[index] = true
+			indices = append(indices, index)
+		}
+	}
+	sort.Slice(indices, func(i, j int) bool {
+		a, _ := strconv.ParseInt(indices[i], 10, 64)
+		b, _ := strconv.ParseInt(indices[j], 10, 64)
+		return a < b
+	})
+	return indices, nil
+}
+
+// A mapElement is an entry of an indexed map, held in a scratch value until
+// its variables have been processed.
+type mapElement struct {
+	key   reflect.Value
+	value reflect.Value
+	infos []VarInfo
+}
+
+// mapElements returns an element for each index of the indexed map described
+// by info that is set in env, ignoring the variables in claimed. Each starts
+// from the map's current entry, if any.
+func mapElements(info VarInfo, env environment, claimed map[string]struct{}) ([]mapElement, error) {
+	typ := info.Field.Type()
+	structs := isPlainStruct(mapValueType(typ))
+	indices, err := mapIndices(info.Key, structs, env, claimed)
+	if err != nil {
+		return nil, err
+	}
+
+	var elems []mapElement
+	for _, index := range indices {
+		k := reflect.New(typ.Key()).Elem()
+		if err := processField(index, k, ""); err != nil {
+			return nil, &ParseError{
+				KeyName:   info.Key + "_" + index,
+				FieldName: info.Name,
+				FieldPath: info.Path,
+				TypeName:  typ.Key().String(),
+				Value:     index,
+				Err:       err,
+			}
+		}
+		v := reflect.New(typ.Elem()).Elem()
+		if !info.Field.IsNil() {
+			if old := info.Field.MapIndex(k); old.IsValid() {
+				v.Set(old)
+			}
+		}
+
+		e := mapElement{key: k, value: v}
+		key := info.Key + "_" + index
+		path := fmt.Sprintf("%s[%s]", info.Path, index)
+		if !structs {
+			e.infos = []VarInfo{{Name: info.Name, Path: path, Key: key, Field: v, Tags: info.Tags}}
+			elems = append(elems, e)
+			continue
+		}
+		target := v
+		if target.Kind() == reflect.Ptr {
+			if target.IsNil() {
+				target.Set(reflect.New(target.Type().Elem()))
+			}
+			target = target.Elem()
+		}
+		e.infos, err = gatherInfo(key, target.Addr().Interface())
+		if err != nil {
+			return nil, err
+		}
+		for j := range e.infos {
+			// an unprefixed name would be shared by every element
+			e.infos[j].Alt = ""
+			e.infos[j].Deprecated = ""
+			e.infos[j].Fallbacks = nil
+			e.infos[j].Path = path + "." + e.infos[j].Path
+		}
+		elems = append(elems, e)
+	}
+	return elems, nil
+}
+
+// processIndexedMap populates a map from indexed variables. A value set for
+// the map's own key is first parsed as usual; indexed variables such as
+// KEY_5 then set individual entries.
+func processIndexedMap(value string, src Source, info VarInfo, o *options, claimed map[string]struct{}) ([]VarInfo, error) {
+	if src != SourceUnset {
+		if err := processVar(value, info); err != nil {
+			if err = o.fieldError(info, err); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	elems, err := mapElements(info, o.env, claimed)
+	if err != nil {
+		return nil, err
+	}
+	if len(elems) > 0 && info.Field.IsNil() {
+		info.Field.Set(reflect.MakeMap(info.Field.Type()))
+	}
+
+	elemOpts := *o
+	// registered defaults are keyed by field paths of the outer spec, which
+	// do not reach into elements
+	elemOpts.registered = nil
+	var processed []VarInfo
+	for _, e := range elems {
+		infos, err := processInfos(e.infos, &elemOpts, nil)
+		if err != nil {
+			return nil, err
+		}
+		info.Field.SetMapIndex(e.key, e.value)
+		processed = append(processed, infos...)
+	}
+	return processed, nil
+}
+
+// indexedLen returns one more than the highest index N for which a variable
+// named key_N_* is set, or zero if there is none.
+func indexedLen(key string, env environment) int {
+	key += "_"
+	n := 0
+	for _, kv := range env.environ() {
+		name := strings.SplitN(kv, "=", 2)[0]
+		if !strings.HasPrefix(name, key) {
+			continue
+		}
+		rest := name[len(key):]
+		end := strings.IndexByte(rest, '_')
+		if end <= 0 {
+			continue
+		}
+		i, err := strconv.Atoi(rest[:end])
+		if err == nil && i >= n {
+			n = i + 1
+		}
+	}
+	return n
+}
+
+// elementInfos gathers the variables of each element of slice, the value of
+// the indexed field described by info.
+func elementInfos(info VarInfo, slice reflect.Value) ([]VarInfo, error) {
+	var infos []VarInfo
+	for i := 0; i < slice.Len(); i++ {
+		elem := slice.Index(i)
+		if elem.Kind() == reflect.Ptr {
+			if elem.IsNil() {
+				elem.Set(reflect.New(elem.Type().Elem()))
+			}
+			elem = elem.Elem()
+		}
+		elemInfos, err := gatherInfo(fmt.Sprintf("%s_%d", info.Key, i), elem.Addr().Interface())
+		if err != nil {
+			return nil, err
+		}
+		for j := range elemInfos {
+			// an unprefixed name would be shared by every element
+			elemInfos[j].Alt = ""
+			elemInfos[j].Deprecated = ""
+			elemInfos[j].Fallbacks = nil
+			elemInfos[j].Path = fmt.Sprintf("%s[%d].%s", info.Path, i, elemInfos[j].Path)
+		}
+		infos = append(infos, elemInfos...)
+	}
+	return infos, nil
+}
+
+// expandIndexed returns infos extended with the variables that the elements
+// of indexed slices may be configured through. The elements are gathered from
+// scratch values, so the specification is not modified.
+func expandIndexed(infos []VarInfo, env environment) ([]VarInfo, error) {
+	expanded := infos
+	for _, info := range infos {
+		if !info.Indexed {
+			continue
+		}
+		if info.Field.Kind() == reflect.Map {
+			// gather from a scratch map, as elements of structs are allocated
+			scratch := info
+			scratch.Field = reflect.New(info.Field.Type()).Elem()
+			elems, err := mapElements(scratch, env, claimedKeys(infos))
+			if err != nil {
+				return nil, err
+			}
+			for _, e := range elems {
+				infos, err := expandIndexed(e.infos, env)
+				if err != nil {
+					return nil, err
+				}
+				expanded = append(expanded, infos...)
+			}
+			continue
+		}
+		n := indexedLen(info.Key, env)
+		if info.Field.Len() > n {
+			n = info.Field.Len()
+		}
+		elems, err := elementInfos(info, reflect.MakeSlice(info.Field.Type(), n, n))
+		if err != nil {
+			return nil, err
+		}
+		elems, err = expandIndexed(elems, env)
+		if err != nil {
+			return nil, err
+		}
+		expanded = append(expanded, elems...)
+	}
+	return expanded, nil
+}
+
+// DefaultValuer is implemented by specifications that supply defaults which
+// cannot be written as a default tag, such as populated maps or slices.
+type DefaultValuer interface {
+	// DefaultValues returns default values keyed by struct field name, or by
+	// the dotted path of a nested field, such as "DB.Port".
+	DefaultValues() map[string]interface{}
+}
+
+var (
+	registeredMu       sync.RWMutex
+	registeredDefaults = make(map[reflect.Type]map[string]string)
+)
+
+// SetDefaults registers default values for every specification of the same
+// type as spec, keyed by struct field name, or by the dotted path of a nested
+// field such as "DB.Port". They are written as default tags
+// would be and are used for fields whose variables are unset and that have no
+// default tag. This lets a library define its configuration type and its
+// defaults separately. SetDefaults is safe for concurrent use.
+func SetDefaults(spec interface{}, defaults map[string]string) error {
+	t := reflect.TypeOf(spec)
+	if t == nil || t.Kind() != reflect.Ptr || t.Elem().Kind() != reflect.Struct {
+		return ErrInvalidSpecification
+	}
+	infos, err := gatherInfo("", reflect.New(t.Elem()).Interface())
+	if err != nil {
+		return err
+	}
+	paths := make(map[string]bool, len(infos))
+	for _, info := range infos {
+		paths[info.Path] = true
+	}
+
+	registered := make(map[string]string, len(defaults))
+	for name, def := range defaults {
+		if !paths[name] {
+			return fmt.Errorf("envconfig: no field %s for default value", name)
+		}
+		registered[name] = def
+	}
+
+	registeredMu.Lock()
+	defer registeredMu.Unlock()
+	registeredDefaults[t.Elem()] = registered
+	return nil
+}
+
+// registeredDefaultsFor returns the defaults registered for the type of spec.
+func registeredDefaultsFor(spec interface{}) map[string]string {
+	t := reflect.TypeOf(spec)
+	if t == nil || t.Kind() != reflect.Ptr {
+		return nil
+	}
+	registeredMu.RLock()
+	defer registeredMu.RUnlock()
+	return registeredDefaults[t.Elem()]
+}
+
+// defaultValues returns the defaults supplied by spec's DefaultValues method,
+// if it has one.
+func defaultValues(spec interface{}, o *options) map[string]interface{} {
+	if dv, ok := spec.(DefaultValuer); ok && !o.noDefaults {
+		return dv.DefaultValues()
+	}
+	return nil
+}
+
+// assignDefaultValue assigns the default registered for info's field by a
+// DefaultValues method, provided the field still holds its zero value.
+func assignDefaultValue(info VarInfo, defaults map[string]interface{}) (bool, error) {
+	def, ok := defaults[info.Path]
+	if !ok || !info.Field.IsZero() {
+		return false, nil
+	}
+	v := reflect.ValueOf(def)
+	if !v.IsValid() || !v.Type().AssignableTo(info.Field.Type()) {
+		return false, fmt.Errorf("envconfig: default value for %s is %T, not %s", info.Path, def, info.Field.Type())
+	}
+	info.Field.Set(v)
+	return true, nil
+}
+
+// defaultSources is the order in which values are looked for unless
+// WithSources or a sources tag says otherwise.
+var defaultSources = []Source{SourceEnv, SourceDefault}
+
+// parseSources parses a comma-separated list of sources, as held by the
+// sources tag.
+func parseSources(list string) ([]Source, error) {
+	var sources []Source
+	for _, name := range strings.Split(list, ",") {
+		src := Source(strings.TrimSpace(name))
+		if src != SourceEnv && src != SourceDefault {
+			return nil, fmt.Errorf("unknown source %q", src)
+		}
+		sources = append(sources, src)
+	}
+	return sources, nil
+}
+
+// resolve looks up the value of the variable described by info, trying its
+// sources in order, and reports where the value came from.
+func resolve(info VarInfo, o *options) (string, Source) {
+	if o.onResolve != nil {
+		defer o.onResolve(info)()
+	}
+	def := info.Tags.Get("default")
+	if def == "" {
+		def = o.registered[info.Path]
+	}
+	if o.noDefaults {
+		def = ""
+	}
+
+	sources := defaultSources
+	if o.sources != nil {
+		sources = o.sources
+	}
+	if list := info.Tags.Get("sources"); list != "" {
+		// gatherInfo has rejected invalid lists
+		sources, _ = parseSources(list)
+	}
+
+	for _, source := range sources {
+		switch source {
+		case SourceEnv:
+			value, src := lookupVar(info, o)
+			if src == SourceUnset {
+				continue
+			}
+			if isTrue(info.Tags.Get("appendable")) && strings.HasPrefix(value, "+") {
+				// a leading + extends the default list instead of replacing it
+				value = value[1:]
+				if def != "" {
+					// an invalid sep is reported when the value is processed
+					sep, _ := listSeparator(info.Tags)
+					value = joinList(expandDefault(def, o.env), value, sep)
+				}
+				return value, src
+			}
+			// an explicitly empty value wins over the default unless the
+			// field opts out with keep_default_on_empty
+			if value != "" || def == "" || !isTrue(info.Tags.Get("keep_default_on_empty")) {
+				return value, src
+			}
+		case SourceDefault:
+			if def != "" {
+				return expandDefault(def, o.env), SourceDefault
+			}
+		}
+	}
+	return "", SourceUnset
+}
+
+// joinList joins two lists written with the separator sep, either of which
+// may be empty.
+func joinList(a, b, sep string) string {
+	if a == "" || b == "" {
+		return a + b
+	}
+	return a + sep + b
+}
+
+// A candidate is a name that a variable may be read from.
+type candidate struct {
+	key string
+	src Source
+}
+
+// candidates lists the names that the variable described by info is read
+// from, in the order they are tried: its key and its fallback keys, then its
+// alternate name, then its deprecated name. WithAltPrecedence moves the
+// alternate name first.
+func candidates(info VarInfo, o *options) []candidate {
+	var cs []candidate
+	if o.altFirst && info.Alt != "" {
+		cs = append(cs, candidate{info.Alt, SourceAlt})
+	}
+	cs = append(cs, candidate{info.Key, SourceEnv})
+	for _, key := range info.Fallbacks {
+		cs = append(cs, candidate{key, SourceEnv})
+	}
+	if !o.altFirst && info.Alt != "" {
+		cs = append(cs, candidate{info.Alt, SourceAlt})
+	}
+	if info.Deprecated != "" {
+		cs = append(cs, candidate{info.Deprecated, SourceDeprecated})
+	}
+	return cs
+}
+
+// lookupVar looks up the variable described by info in the environment,
+// trying each of its candidates in turn.
+func lookupVar(info VarInfo, o *options) (string, Source) {
+	for _, c := range candidates(info, o) {
+		if value, ok := o.env.lookup(c.key); ok {
+			return value, c.src
+		}
+	}
+	return "", SourceUnset
+}
+
+// expandDefault replaces ${VAR} and ${VAR:-fallback} references in a default
+// value with the value of VAR from the environment, and ${@name} references
+// with the result of the default provider named name. As in the shell, the
+// fallback is used when VAR is unset or empty, and an unset VAR without a
+// fallback expands to the empty string. Any other text is kept verbatim.
+func expandDefault(def string, env environment) string {
+	return scanDefault(def, func(name, fallback string) string {
+		var value string
+		if provider, ok := providerRef(name); ok {
+			if fn, ok := lookupProvider(provider); ok {
+				value = fn()
+			}
+		} else {
+			value, _ = env.lookup(name)
+		}
+		if value == "" {
+			return fallback
+		}
+		return value
+	})
+}
+
+// scanDefault replaces each ${VAR} and ${VAR:-fallback} reference in def with
+// the result of expand.
+func scanDefault(def string, expand func(name, fallback string) string) string {
+	var buf strings.Builder
+	for {
+		start := strings.Index(def, "${")
+		if start < 0 {
+			break
+		}
+		end := strings.IndexByte(def[start:], '}')
+		if end < 0 {
+			break
+		}
+		end += start
+
+		name, fallback := def[start+2:end], ""
+		if i := strings.Index(name, ":-"); i >= 0 {
+			name, fallback = name[:i], name[i+2:]
+		}
+		buf.WriteString(def[:start])
+		buf.WriteString(expand(name, fallback))
+		def = def[end+1:]
+	}
+	buf.WriteString(def)
+	return buf.String()
+}
+
+// missingError reports that the required variable described by info has no
+// value.
+func missingError(info VarInfo) error {
+	return fmt.Errorf("required key %s missing value", missingKey(info))
+}
+
+// missingKey returns the name a missing variable is reported under.
+func missingKey(info VarInfo) string {
+	if info.Alt != "" {
+		return info.Alt
+	}
+	return info.Key
+}
+
+// missingKeysError reports every variable in missing as unset.
+func missingKeysError(missing []VarInfo) error {
+	if len(missing) == 1 {
+		return missingError(missing[0])
+	}
+	keys := make([]string, len(missing))
+	for i, info := range missing {
+		keys[i] = missingKey(info)
+	}
+	return fmt.Errorf("required keys %s missing value", strings.Join(keys, ", "))
+}
+
+// processVar assigns a resolved value to the field described by info.
+func processVar(value string, info VarInfo) error {
+	if isTrue(info.Tags.Get("strip_inline_comment")) {
+		value = stripInlineComment(value)
+	}
+	if lv, ok := lazyFrom(info.Field); ok {
+		lv.setLazy(value, info)
+		return nil
+	}
+	if isTrue(info.Tags.Get("from_file")) {
+		content, err := readValueFile(value, info.Tags)
+		if err != nil {
+			return &ParseError{
+				KeyName:   info.Key,
+				FieldName: info.Name,
+				FieldPath: info.Path,
+				TypeName:  info.Field.Type().String(),
+				Value:     value,
+				Err:       err,
+			}
+		}
+		value = content
+	}
+	if err := processField(value, info.Field, info.Tags); err != nil {
+		return &ParseError{
+			KeyName:   info.Key,
+			FieldName: info.Name,
+			FieldPath: info.Path,
+			TypeName:  info.Field.Type().String(),
+			Value:     value,
+			Err:       err,
+			Redacted:  isTrue(info.Tags.Get("secret")),
+		}
+	}
+	return nil
+}
+
+// readValueFile returns the contents of the file at path, for fields tagged
+// from_file:"true". A max_bytes tag limits how much of the file may be read.
+func readValueFile(path string, tags reflect.StructTag) (string, error) {
+	limit := int64(-1)
+	if max := tags.Get("max_bytes"); max != "" {
+		n, err := strconv.ParseInt(max, 0, 64)
+		if err != nil || n < 0 {
+			return "", fmt.Errorf("invalid max_bytes %q", max)
+		}
+		limit = n
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	var r io.Reader = f
+	if limit >= 0 {
+		// read one byte past the limit to detect larger files
+		r = io.LimitReader(f, limit+1)
+	}
+	b, err := ioutil.ReadAll(r)
+	if err != nil {
+		return "", err
+	}
+	if limit >= 0 && int64(len(b)) > limit {
+		return "", fmt.Errorf("file %s exceeds %d bytes", path, limit)
+	}
+	return string(b), nil
+}
+
+// MustProcess is the same as Process but panics if an error occurs
+func MustProcess(prefix string, spec interface{}, opts ...Option) {
+	if err := Process(prefix, spec, opts...); err != nil {
+		panic(err)
+	}
+}
+
+func processField(value string, field reflect.Value, tags reflect.StructTag) error {
+	typ := field.Type()
+	container := isContainer(typ) && !isRunes(typ, tags)
+
+	// containers trim and transform each element instead
+	if isTrue(tags.Get("trim")) && !container {
+		value = strings.TrimSpace(value)
+	}
+	if tag := tags.Get("transform"); tag != "" && !container {
+		var err error
+		if value, err = applyTransforms(value, tag); err != nil {
+			return err
+		}
+	}
+
+	if isTrue(tags.Get("json")) {
+		v := reflect.New(typ)
+		if err := json.Unmarshal([]byte(value), v.Interface()); err != nil {
+			return err
+		}
+		field.Set(v.Elem())
+		return nil
+	}
+
+	if isTrue(tags.Get("dequote")) && !container {
+		value = dequote(value)
+	}
+
+	if allowed := tags.Get("oneof"); allowed != "" && !container {
+		var err error
+		value, err = matchOneOf(value, strings.Fields(allowed), isTrue(tags.Get("oneof_ci")))
+		if err != nil {
+			return err
+		}
+	}
+
+	if name := tags.Get("parser"); name != "" {
+		return processParser(name, value, field)
+	}
+
+	if typ == urlType || typ == reflect.PtrTo(urlType) {
+		return processURL(value, field)
+	}
+
+	if layout := tags.Get("time_format"); layout != "" && (typ == timeType || typ == reflect.PtrTo(timeType)) {
+		return processTime(value, layout, field)
+	}
+
+	if typ.Kind() == reflect.Ptr && field.IsNil() && implementsInterface(typ.Elem()) {
+		// methods with pointer receivers would be called on nil
+		field.Set(reflect.New(typ.Elem()))
+	}
+
+	decoder := decoderFrom(field)
+	if decoder != nil {
+		return decoder.Decode(value)
+	}
+	// look for Set method if Decode not defined
+	setter := setterFrom(field)
+	if setter != nil {
+		return setter.Set(value)
+	}
+
+	if t := textUnmarshaler(field); t != nil {
+		return t.UnmarshalText([]byte(value))
+	}
+
+	if b := binaryUnmarshaler(field); b != nil {
+		return b.UnmarshalBinary([]byte(value))
+	}
+
+	if d := elementDecoderFrom(field); d != nil {
+		return appendElements(value, field, d, tags)
+	}
+
+	if typ == locationType || typ == reflect.PtrTo(locationType) {
+		return processLocation(value, field)
+	}
+
+	if typ == ipNetType || typ == reflect.PtrTo(ipNetType) {
+		return processIPNet(value, field)
+	}
+
+	// allocate each level of pointers such as **int
+	for typ.Kind() == reflect.Ptr {
+		typ = typ.Elem()
+		if field.IsNil() {
+			field.Set(reflect.New(typ))
+		}
+		field = field.Elem()
+	}
+
+	if e := lookupEnum(typ); e != nil {
+		return e.set(value, field, tags)
+	}
+
+	if typ == hardwareAddrType {
+		return processHardwareAddr(value, field)
+	}
+
+	switch typ.Kind() {
+	case reflect.String:
+		field.SetString(value)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		var (
+			val int64
+			err error
+		)
+		if field.Kind() == reflect.Int64 && typ.PkgPath() == "time" && typ.Name() == "Duration" {
+			var d time.Duration
+			d, err = parseDuration(value, tags.Get("unit"))
+			if err == nil {
+				err = checkDurationBounds(d, tags)
+			}
+			val = int64(d)
+		} else {
+			val, err = strconv.ParseInt(trimNumber(value), 0, typ.Bits())
+			err = checkRange(err, value, typ)
+		}
+		if err != nil {
+			return err
+		}
+
+		field.SetInt(val)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		val, err := strconv.ParseUint(trimNumber(value), 0, typ.Bits())
+		if err != nil {
+			return checkRange(err, value, typ)
+		}
+		field.SetUint(val)
+	case reflect.Bool:
+		val, err := parseBool(strings.TrimSpace(value), tags.Get("boolstyle"))
+		if err != nil {
+			return err
+		}
+		field.SetBool(val)
+	case reflect.Float32, reflect.Float64:
+		val, err := strconv.ParseFloat(trimNumber(value), typ.Bits())
+		if err != nil {
+			return checkRange(err, value, typ)
+		}
+		if isTrue(tags.Get("finite")) && (math.IsInf(val, 0) || math.IsNaN(val)) {
+			return fmt.Errorf("value %q is not a finite number", value)
+		}
+		field.SetFloat(val)
+	case reflect.Complex64, reflect.Complex128:
+		val, err := strconv.ParseComplex(value, typ.Bits())
+		if err != nil {
+			return checkRange(err, value, typ)
+		}
+		field.SetComplex(val)
+	case reflect.Slice:
+		sl := reflect.MakeSlice(typ, 0, 0)
+		if typ.Elem().Kind() == reflect.Uint8 {
+			b, err := decodeBytes(value, tags)
+			if err != nil {
+				return err
+			}
+			sl = reflect.ValueOf(b)
+		} else if isRunes(typ, tags) {
+			sl = reflect.ValueOf([]rune(value))
+		} else if strings.TrimSpace(value) != "" {
+			vals, err := splitList(value, tags)
+			if err != nil {
+				return err
+			}
+			elemTags, err := elementTags(typ.Elem(), tags)
+			if err != nil {
+				return err
+			}
+			sl = reflect.MakeSlice(typ, len(vals), len(vals))
+			for i, val := range vals {
+				err := processField(val, sl.Index(i), elemTags)
+				if err != nil {
+					return fmt.Errorf("element %d: %w", i, err)
+				}
+			}
+		}
+		field.Set(sl)
+	case reflect.Array:
+		if typ.Elem().Kind() != reflect.Uint8 {
+			return fmt.Errorf("unsupported type %s", typ)
+		}
+		b, err := decodeBytes(value, tags)
+		if err != nil {
+			return err
+		}
+		if len(b) != typ.Len() {
+			return fmt.Errorf("expected %d bytes, got %d", typ.Len(), len(b))
+		}
+		reflect.Copy(field, reflect.ValueOf(b))
+	case reflect.Map:
+		if isTrue(tags.Get("query")) {
+			return processQuery(value, field)
+		}
+		mp := reflect.MakeMap(typ)
+		if strings.TrimSpace(value) != "" {
+			sep, err := listSeparator(tags)
+			if err != nil {
+				return err
+			}
+			mapsep, err := mapSeparator(tags)
+			if err != nil {
+				return err
+			}
+			// the list in each value is split on its own separator
+			valueTags, err := elementTags(typ.Elem(), tags)
+			if err != nil {
+				return err
+			}
+			pairs := strings.Split(value, sep)
+			for _, pair := range pairs {
+				kvpair := strings.Split(pair, mapsep)
+				if len(kvpair) != 2 {
+					return fmt.Errorf("invalid map item: %q", pair)
+				}
+				k := reflect.New(typ.Key()).Elem()
+				err := processField(kvpair[0], k, tags)
+				if err != nil {
+					return fmt.Errorf("map item %q: %w", pair, err)
+				}
+				v := reflect.New(typ.Elem()).Elem()
+				err = processField(kvpair[1], v, valueTags)
+				if err != nil {
+					return fmt.Errorf("map item %q: %w", pair, err)
+				}
+				mp.SetMapIndex(k, v)
+			}
+		}
+		field.Set(mp)
+	default:
+		// never leave a value silently unassigned
+		return fmt.Errorf("unsupported type %s", typ)
+	}
+
+	return nil
+}
+
+// dequote removes a single pair of matching single or double quotes around
+// value, leaving unbalanced quotes in place.
+func dequote(value string) string {
+	if len(value) >= 2 {
+		if q := value[0]; (q == '\'' || q == '"') && value[len(value)-1] == q {
+			return value[1 : len(value)-1]
+		}
+	}
+	return value
+}
+
+// stripInlineComment removes a trailing comment, a # preceded by a space or
+// tab, from value together with the blanks before it. A # inside single or
+// double quotes, or not preceded by a blank, is kept.
+func stripInlineComment(value string) string {
+	var quote byte
+	for i := 0; i < len(value); i++ {
+		switch c := value[i]; {
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case c == '\'' || c == '"':
+			quote = c
+		case c == '#' && i > 0 && (value[i-1] == ' ' || value[i-1] == '\t'):
+			return strings.TrimRight(value[:i], " \t")
+		}
+	}
+	return value
+}
+
+// tagOr returns the value of the named tag, or def if it is unset.
+func tagOr(tags reflect.StructTag, name, def string) string {
+	if v := tags.Get(name); v != "" {
+		return v
+	}
+	return def
+}
+
+// validSeparators are the characters that the sep, mapsep and listsep tags
+// may hold.
+const validSeparators = ",;:|=/ \t"
+
+// separatorNames name the valid separators in usage descriptions.
+var separatorNames = map[string]string{
+	",":  "Comma",
+	";":  "Semicolon",
+	":":  "Colon",
+	"|":  "Pipe",
+	"=":  "Equals",
+	"/":  "Slash",
+	" ":  "Space",
+	"\t": "Tab",
+}
+
+// separator resolves the separator held by the named tag, or def when it is
+// unset. Processing and usage descriptions both resolve separators through
+// it, so that the two always agree. The tag's value is returned along with
+// the error when it is not a single character of validSeparators.
+func separator(tags reflect.StructTag, name, def string) (string, error) {
+	sep := tagOr(tags, name, def)
+	if len(sep) != 1 || !strings.Contains(validSeparators, sep) {
+		return sep, fmt.Errorf("invalid %s %q", name, sep)
+	}
+	return sep, nil
+}
+
+// listSeparator resolves the separator between list elements or map pairs.
+// map_sep is accepted in place of sep.
+func listSeparator(tags reflect.StructTag) (string, error) {
+	if tags.Get("sep") == "" && tags.Get("map_sep") != "" {
+		return separator(tags, "map_sep", ",")
+	}
+	return separator(tags, "sep", ",")
+}
+
+// mapSeparator resolves the separator between a map key and its value.
+// kv_sep is accepted in place of mapsep.
+func mapSeparator(tags reflect.StructTag) (string, error) {
+	if tags.Get("mapsep") == "" && tags.Get("kv_sep") != "" {
+		return separator(tags, "kv_sep", ":")
+	}
+	return separator(tags, "mapsep", ":")
+}
+
+// elementTags returns the tags that the elements of a container of type t
+// are processed with: lists nested in a container are split on listsep.
+func elementTags(t reflect.Type, tags reflect.StructTag) (reflect.StructTag, error) {
+	if !isContainer(t) {
+		return tags, nil
+	}
+	sep, err := separator(tags, "listsep", "|")
+	if err != nil {
+		return tags, err
+	}
+	return withTag(tags, "sep", sep), nil
+}
+
+// withTag returns tags with the named tag set to value, taking precedence
+// over any existing one.
+func withTag(tags reflect.StructTag, name, value string) reflect.StructTag {
+	return reflect.StructTag(name + ":" + strconv.Quote(value) + " " + string(tags))
+}
+
+// trimNumber removes the whitespace around a number and a single leading
+// plus sign, which some tools emit, before it is parsed. Anything else, such
+// as internal spaces, is left for the parser to reject.
+func trimNumber(value string) string {
+	value = strings.TrimSpace(value)
+	if len(value) > 1 && value[0] == '+' && value[1] != '+' && value[1] != '-' {
+		value = value[1:]
+	}
+	return value
+}
+
+// parseDuration parses a duration as time.ParseDuration does. When unit is
+// set, a bare number such as "30" or "1.5" is read in that unit, so "1.5"
+// is 1500ms for the unit "s". Defaults are parsed the same way.
+func parseDuration(value, unit string) (time.Duration, error) {
+	if unit != "" {
+		if f, err := strconv.ParseFloat(value, 64); err == nil {
+			scale, err := time.ParseDuration("1" + unit)
+			if err != nil {
+				return 0, err
+			}
+			d := math.Round(f * float64(scale))
+			if math.IsNaN(d) || d >= math.MaxInt64 || d < math.MinInt64 {
+				return 0, fmt.Errorf("duration %s%s out of range", value, unit)
+			}
+			return time.Duration(d), nil
+		}
+	}
+	return time.ParseDuration(value)
+}
+
+// checkDurationBounds checks d against the durations in the min and max
+// tags, if present.
+func checkDurationBounds(d time.Duration, tags reflect.StructTag) error {
+	if min := tags.Get("min"); min != "" {
+		bound, err := time.ParseDuration(min)
+		if err != nil {
+			return fmt.Errorf("invalid min %q: %v", min, err)
+		}
+		if d < bound {
+			return fmt.Errorf("duration %s is below the minimum of %s", d, bound)
+		}
+	}
+	if max := tags.Get("max"); max != "" {
+		bound, err := time.ParseDuration(max)
+		if err != nil {
+			return fmt.Errorf("invalid max %q: %v", max, err)
+		}
+		if d > bound {
+			return fmt.Errorf("duration %s is above the maximum of %s", d, bound)
+		}
+	}
+	return nil
+}
+
+// parseBool parses value as a boolean in the given style. The default style
+// is that of strconv.ParseBool; "numeric" accepts any integer, with non-zero
+// values being true.
+func parseBool(value, style string) (bool, error) {
+	switch style {
+	case "":
+		return strconv.ParseBool(value)
+	case "numeric":
+		n, err := strconv.ParseInt(value, 0, 64)
+		if err != nil {
+			return false, err
+		}
+		return n != 0, nil
+	default:
+		return false, fmt.Errorf("unknown boolstyle %q", style)
+	}
+}
+
+// decodeBytes converts value to bytes using the encoding named by the
+// encoding tag, or verbatim if there is none.
+func decodeBytes(value string, tags reflect.StructTag) ([]byte, error) {
+	switch enc := tags.Get("encoding"); enc {
+	case "":
+		return []byte(value), nil
+	case "hex":
+		return hex.DecodeString(value)
+	case "base64":
+		return base64.StdEncoding.DecodeString(value)
+	case "base32":
+		// OTP secrets in particular are often given without padding
+		return base32.StdEncoding.WithPadding(base32.NoPadding).DecodeString(strings.TrimRight(value, "="))
+	default:
+		return nil, fmt.Errorf("unknown encoding %q", enc)
+	}
+}
+
+// encodeBytes is the inverse of decodeBytes.
+func encodeBytes(b []byte, tags reflect.StructTag) string {
+	switch tags.Get("encoding") {
+	case "hex":
+		return hex.EncodeToString(b)
+	case "base64":
+		return base64.StdEncoding.EncodeToString(b)
+	case "base32":
+		return base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString(b)
+	}
+	return string(b)
+}
+
+// dropEmpty removes the empty strings from vals, reusing its storage.
+func dropEmpty(vals []string) []string {
+	kept := vals[:0]
+	for _, val := range vals {
+		if val != "" {
+			kept = append(kept, val)
+		}
+	}
+	return kept
+}
+
+// isContainer reports whether values of type t are split into elements that
+// are processed individually.
+func isContainer(t reflect.Type) bool {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if implementsInterface(t) {
+		return false
+	}
+	switch t.Kind() {
+	case reflect.Slice:
+		return t.Elem().Kind() != reflect.Uint8
+	case reflect.Map:
+		return true
+	}
+	return false
+}
+
+// isRunes reports whether t is a []rune to be assigned the runes of its
+// value. As rune is an alias of int32, this has to be asked for with the
+// runes tag, so that []int32 fields are still split on commas.
+func isRunes(t reflect.Type, tags reflect.StructTag) bool {
+	return t.Kind() == reflect.Slice && t.Elem() == runeType && isTrue(tags.Get("runes"))
+}
+
+// matchOneOf checks that value is one of the allowed values. When ignoreCase
+// is set the comparison is case-insensitive and the allowed value, with its
+// canonical casing, is returned in place of value.
+func matchOneOf(value string, allowed []string, ignoreCase bool) (string, error) {
+	for _, a := range allowed {
+		if a == value {
+			return value, nil
+		}
+	}
+	if ignoreCase {
+		for _, a := range allowed {
+			if strings.EqualFold(a, value) {
+				return a, nil
+			}
+		}
+	}
+	return "", fmt.Errorf("value %q is not one of %s", value, strings.Join(allowed, ", "))
+}
+
+var locationType = reflect.TypeOf(time.Location{})
+
+// isKnownType reports whether t is a struct type that processField decodes
+// itself, rather than one to be processed as a nested specification.
+func isKnownType(t reflect.Type) bool {
+	return t == locationType || t == ipNetType || t == timeType || t == urlType ||
+		reflect.PtrTo(t).Implements(lazyValueType)
+}
+
+// A lazyValue stores its raw value when processed and decodes it on first
+// use; it is implemented by Lazy.
+type lazyValue interface {
+	setLazy(value string, info VarInfo)
+	valueType() reflect.Type
+	// rawValue returns the stored value, which is empty if it was unset.
+	rawValue() string
+}
+
+var lazyValueType = reflect.TypeOf((*lazyValue)(nil)).Elem()
+
+// lazyFrom returns field as a lazyValue, allocating a nil pointer to one.
+func lazyFrom(field reflect.Value) (lazyValue, bool) {
+	if field.Kind() == reflect.Ptr && reflect.PtrTo(field.Type().Elem()).Implements(lazyValueType) {
+		if field.IsNil() {
+			field.Set(reflect.New(field.Type().Elem()))
+		}
+		field = field.Elem()
+	}
+	if !field.CanAddr() {
+		return nil, false
+	}
+	lv, ok := field.Addr().Interface().(lazyValue)
+	return lv, ok
+}
+
+var timeType = reflect.TypeOf(time.Time{})
+
+// processTime parses value with the layout of a time_format tag into a
+// time.Time or *time.Time field. An empty value leaves a pointer nil.
+func processTime(value, layout string, field reflect.Value) error {
+	if value == "" {
+		field.Set(reflect.Zero(field.Type()))
+		return nil
+	}
+	t, err := time.Parse(layout, value)
+	if err != nil {
+		return err
+	}
+	if field.Kind() == reflect.Ptr {
+		field.Set(reflect.ValueOf(&t))
+	} else {
+		field.Set(reflect.ValueOf(t))
+	}
+	return nil
+}
+
+// processLocation loads the time zone named by value into a time.Location
+// or *time.Location field. An empty value leaves a pointer nil.
+func processLocation(value string, field reflect.Value) error {
+	if value == "" {
+		field.Set(reflect.Zero(field.Type()))
+		return nil
+	}
+	loc, err := time.LoadLocation(value)
+	if err != nil {
+		return err
+	}
+	if field.Kind() == reflect.Ptr {
+		field.Set(reflect.ValueOf(loc))
+	} else {
+		field.Set(reflect.ValueOf(loc).Elem())
+	}
+	return nil
+}
+
+var (
+	ipType           = reflect.TypeOf(net.IP(nil))
+	ipNetType        = reflect.TypeOf(net.IPNet{})
+	hardwareAddrType = reflect.TypeOf(net.HardwareAddr(nil))
+)
+
+// processIPNet parses a CIDR such as 10.0.0.0/8 into a net.IPNet or
+// *net.IPNet field. An empty value leaves a pointer nil.
+func processIPNet(value string, field reflect.Value) error {
+	if value == "" {
+		field.Set(reflect.Zero(field.Type()))
+		return nil
+	}
+	_, ipNet, err := net.ParseCIDR(value)
+	if err != nil {
+		return err
+	}
+	if field.Kind() == reflect.Ptr {
+		field.Set(reflect.ValueOf(ipNet))
+	} else {
+		field.Set(reflect.ValueOf(ipNet).Elem())
+	}
+	return nil
+}
+
+// processHardwareAddr parses a MAC address into a net.HardwareAddr field,
+// which would otherwise be taken as raw bytes. An empty value leaves it nil.
+func processHardwareAddr(value string, field reflect.Value) error {
+	if value == "" {
+		field.Set(reflect.Zero(field.Type()))
+		return nil
+	}
+	mac, err := net.ParseMAC(value)
+	if err != nil {
+		return err
+	}
+	field.Set(reflect.ValueOf(mac))
+	return nil
+}
+
+var urlType = reflect.TypeOf(url.URL{})
+
+// rawMessageType fields hold a JSON value, which is checked as it is read.
+var rawMessageType = reflect.TypeOf(json.RawMessage(nil))
+
+// processURL parses value with url.Parse into a url.URL or *url.URL field.
+// An empty value leaves a pointer nil.
+func processURL(value string, field reflect.Value) error {
+	if value == "" {
+		field.Set(reflect.Zero(field.Type()))
+		return nil
+	}
+	u, err := url.Parse(value)
+	if err != nil {
+		return err
+	}
+	if field.Kind() == reflect.Ptr {
+		field.Set(reflect.ValueOf(u))
+	} else {
+		field.Set(reflect.ValueOf(u).Elem())
+	}
+	return nil
+}
+
+var queryType = reflect.TypeOf(url.Values(nil))
+
+// processQuery decodes a URL query string into a url.Values compatible map.
+func processQuery(value string, field reflect.Value) error {
+	if !queryType.ConvertibleTo(field.Type()) {
+		return fmt.Errorf("query tag requires a map[string][]string field, not %s", field.Type())
+	}
+	vals, err := url.ParseQuery(value)
+	if err != nil {
+		return err
+	}
+	field.Set(reflect.ValueOf(vals).Convert(field.Type()))
+	return nil
+}
+
+func interfaceFrom(field reflect.Value, fn func(interface{}, *bool)) {
+	// it may be impossible for a struct field to fail this check
+	if !field.CanInterface() {
+		return
+	}
+	var ok bool
+	fn(field.Interface(), &ok)
+	if !ok && field.CanAddr() {
+		fn(field.Addr().Interface(), &ok)
 	}
 }
 
@@ -376,6 +2370,57 @@ func binaryUnmarshaler(field reflect.Value) (b encoding.BinaryUnmarshaler) {
 	return b
 }
 
+func elementDecoderFrom(field reflect.Value) (d ElementDecoder) {
+	interfaceFrom(field, func(v interface{}, ok *bool) { d, *ok = v.(ElementDecoder) })
+	return d
+}
+
+// splitList splits value into the elements of a list, honoring the sep,
+// trim_elements and skip_empty tags.
+func splitList(value string, tags reflect.StructTag) ([]string, error) {
+	sep, err := listSeparator(tags)
+	if err != nil {
+		return nil, err
+	}
+	vals := strings.Split(value, sep)
+	if isTrue(tags.Get("trim_elements")) {
+		for i := range vals {
+			vals[i] = strings.TrimSpace(vals[i])
+		}
+	}
+	if isTrue(tags.Get("skip_empty")) {
+		vals = dropEmpty(vals)
+	}
+	return vals, nil
+}
+
+// appendElements resets the collection behind d and passes it each element
+// of value. As with slices, an empty value yields an empty collection.
+func appendElements(value string, field reflect.Value, d ElementDecoder, tags reflect.StructTag) error {
+	// reset the value behind a pointer receiver, so elements from an earlier
+	// Process are not kept; a value receiver has nothing to reset through
+	switch {
+	case field.Kind() == reflect.Ptr && !field.Elem().Type().Implements(elementDecoderType):
+		field.Elem().Set(reflect.Zero(field.Elem().Type()))
+	case !field.Type().Implements(elementDecoderType):
+		field.Set(reflect.Zero(field.Type()))
+	}
+
+	if strings.TrimSpace(value) == "" {
+		return nil
+	}
+	vals, err := splitList(value, tags)
+	if err != nil {
+		return err
+	}
+	for _, val := range vals {
+		if err := d.AppendElement(val); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 func isTrue(s string) bool {
 	b, _ := strconv.ParseBool(s)
 	return b
@@ -66,3 +66,33 @@ func TestParseURLError(t *testing.T) {
 		t.Errorf("expected %q, got %q", expectedUnerlyingError, v.Err)
 	}
 }
+
+func TestParseURLUnset(t *testing.T) {
+	var s SpecWithURL
+
+	os.Clearenv()
+	if err := Process("env_config", &s); err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+	if s.UrlPointer != nil {
+		t.Errorf("expected <nil>, got %v", s.UrlPointer)
+	}
+
+	os.Setenv("ENV_CONFIG_URLPOINTER", "")
+	if err := Process("env_config", &s); err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+	if s.UrlPointer != nil {
+		t.Errorf("expected <nil>, got %v", s.UrlPointer)
+	}
+
+	infos, err := gatherInfo("env_config", &s)
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+	for _, info := range infos {
+		if desc := toTypeDescription(info.Field.Type(), info.Tags); desc != "URL" {
+			t.Errorf("expected %q, got %q", "URL", desc)
+		}
+	}
+}
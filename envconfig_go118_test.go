@@ -0,0 +1,84 @@
+// Copyright (c) 2016 Kelsey Hightower and others. All rights reserved.
+// Use of this source code is governed by the MIT License that can be found in
+// the LICENSE file.
+
+//go:build go1.18
+// +build go1.18
+
+package envconfig
+
+import (
+	"fmt"
+	"os"
+	"testing"
+)
+
+type Optional[T any] struct {
+	Value T
+	Valid bool
+}
+
+func (o *Optional[T]) Set(value string) error {
+	if _, err := fmt.Sscan(value, &o.Value); err != nil {
+		return err
+	}
+	o.Valid = true
+	return nil
+}
+
+type OptionalValue[T any] []T
+
+func (o *OptionalValue[T]) Set(value string) error {
+	var v T
+	if _, err := fmt.Sscan(value, &v); err != nil {
+		return err
+	}
+	*o = OptionalValue[T]{v}
+	return nil
+}
+
+func TestGenericSetter(t *testing.T) {
+	var s struct {
+		Port     Optional[int]
+		PortPtr  *Optional[int]
+		Unset    Optional[int]
+		Retries  *OptionalValue[int]
+		NoTries  *OptionalValue[int]
+		Fallback Optional[string] `default:"none"`
+	}
+	os.Clearenv()
+	os.Setenv("ENV_CONFIG_PORT", "8080")
+	os.Setenv("ENV_CONFIG_PORTPTR", "9090")
+	os.Setenv("ENV_CONFIG_RETRIES", "3")
+	if err := Process("env_config", &s); err != nil {
+		t.Fatal(err.Error())
+	}
+	if !s.Port.Valid || s.Port.Value != 8080 {
+		t.Errorf("expected %v, got %v", Optional[int]{8080, true}, s.Port)
+	}
+	if s.PortPtr == nil || !s.PortPtr.Valid || s.PortPtr.Value != 9090 {
+		t.Errorf("expected %v, got %v", Optional[int]{9090, true}, s.PortPtr)
+	}
+	if s.Unset.Valid {
+		t.Errorf("expected unset value to be invalid, got %v", s.Unset)
+	}
+	if s.Retries == nil || len(*s.Retries) != 1 || (*s.Retries)[0] != 3 {
+		t.Errorf("expected %v, got %v", OptionalValue[int]{3}, s.Retries)
+	}
+	if s.NoTries != nil {
+		t.Errorf("expected nil, got %v", s.NoTries)
+	}
+	if !s.Fallback.Valid || s.Fallback.Value != "none" {
+		t.Errorf("expected %v, got %v", Optional[string]{"none", true}, s.Fallback)
+	}
+
+	os.Setenv("ENV_CONFIG_RETRIES", "three")
+	err := Process("env_config", &s)
+	v, ok := err.(*ParseError)
+	if !ok {
+		t.Fatalf("expected ParseError, got %T %v", err, err)
+	}
+	if v.TypeName != "envconfig.OptionalValue[int]" {
+		t.Errorf("expected %s, got %s", "envconfig.OptionalValue[int]", v.TypeName)
+	}
+}
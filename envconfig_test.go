@@ -5,10 +5,20 @@
 package envconfig
 
 import (
+	"bytes"
+	"encoding/json"
+	"errors"
 	"flag"
 	"fmt"
+	"io/ioutil"
+	"log"
+	"math"
+	"math/big"
+	"net"
 	"net/url"
 	"os"
+	"reflect"
+	"strconv"
 	"strings"
 	"testing"
 	"time"
@@ -198,7 +208,7 @@ func TestProcess(t *testing.T) {
 	}
 
 	if s.MultiWordVarWithAutoSplit != 24 {
-		t.Errorf("expected %q, got %q", 24, s.MultiWordVarWithAutoSplit)
+		t.Errorf("expected %d, got %d", 24, s.MultiWordVarWithAutoSplit)
 	}
 
 	if s.MultiWordACRWithAutoSplit != 25 {
@@ -290,6 +300,93 @@ func TestParseErrorUint(t *testing.T) {
 	}
 }
 
+func TestParseErrorRedacted(t *testing.T) {
+	var s struct {
+		Pin int `secret:"true"`
+	}
+	os.Clearenv()
+	os.Setenv("ENV_CONFIG_PIN", "hunter2")
+	err := Process("env_config", &s)
+	v, ok := err.(*ParseError)
+	if !ok {
+		t.Fatalf("expected ParseError, got %T %v", err, err)
+	}
+	if !v.Redacted {
+		t.Errorf("expected Redacted to be set")
+	}
+	if strings.Contains(err.Error(), "hunter2") {
+		t.Errorf("expected the value to be redacted, got %s", err.Error())
+	}
+	if !strings.Contains(err.Error(), "'****'") {
+		t.Errorf("expected %s in %s", "'****'", err.Error())
+	}
+}
+
+func TestParseErrorFieldPath(t *testing.T) {
+	type replica struct {
+		Host string
+		Port int
+	}
+	var s struct {
+		Database struct {
+			Port     int
+			Replicas []replica
+		}
+	}
+	tests := []struct {
+		key  string
+		path string
+	}{
+		{"ENV_CONFIG_DATABASE_PORT", "Database.Port"},
+		{"ENV_CONFIG_DATABASE_REPLICAS_1_PORT", "Database.Replicas[1].Port"},
+	}
+	for _, test := range tests {
+		os.Clearenv()
+		os.Setenv(test.key, "not-a-port")
+		err := Process("env_config", &s)
+		v, ok := err.(*ParseError)
+		if !ok {
+			t.Fatalf("expected ParseError, got %T %v", err, err)
+		}
+		if v.FieldName != "Port" {
+			t.Errorf("expected %s, got %v", "Port", v.FieldName)
+		}
+		if v.FieldPath != test.path {
+			t.Errorf("expected %s, got %v", test.path, v.FieldPath)
+		}
+	}
+}
+
+func TestParseErrorOutOfRange(t *testing.T) {
+	var s struct {
+		Small int8
+		Tiny  uint8
+		Ratio float32
+	}
+	tests := []struct {
+		key, value, want string
+	}{
+		{"ENV_CONFIG_SMALL", "300", "value 300 out of range for int8"},
+		{"ENV_CONFIG_TINY", "256", "value 256 out of range for uint8"},
+		{"ENV_CONFIG_RATIO", "1e39", "value 1e39 out of range for float32"},
+	}
+	for _, test := range tests {
+		os.Clearenv()
+		os.Setenv(test.key, test.value)
+		err := Process("env_config", &s)
+		v, ok := err.(*ParseError)
+		if !ok {
+			t.Fatalf("expected ParseError, got %T %v", err, err)
+		}
+		if v.Err.Error() != test.want {
+			t.Errorf("expected %q, got %q", test.want, v.Err)
+		}
+		if !errors.Is(err, strconv.ErrRange) {
+			t.Errorf("expected error to wrap strconv.ErrRange, got %v", err)
+		}
+	}
+}
+
 func TestParseErrorSplitWords(t *testing.T) {
 	var s Specification
 	os.Clearenv()
@@ -354,146 +451,2616 @@ func TestAlternateVarNames(t *testing.T) {
 		t.Errorf("expected %q, got %q", "bar", s.MultiWordVarWithAlt)
 	}
 
-	// Alt value is not case sensitive and is treated as all uppercase
-	if s.MultiWordVarWithLowerCaseAlt != "baz" {
-		t.Errorf("expected %q, got %q", "baz", s.MultiWordVarWithLowerCaseAlt)
+	// Alt value is not case sensitive and is treated as all uppercase
+	if s.MultiWordVarWithLowerCaseAlt != "baz" {
+		t.Errorf("expected %q, got %q", "baz", s.MultiWordVarWithLowerCaseAlt)
+	}
+}
+
+func TestRequiredVar(t *testing.T) {
+	var s Specification
+	os.Clearenv()
+	os.Setenv("ENV_CONFIG_REQUIREDVAR", "foobar")
+	if err := Process("env_config", &s); err != nil {
+		t.Error(err.Error())
+	}
+
+	if s.RequiredVar != "foobar" {
+		t.Errorf("expected %s, got %s", "foobar", s.RequiredVar)
+	}
+}
+
+func TestRequiredMissing(t *testing.T) {
+	var s Specification
+	os.Clearenv()
+
+	err := Process("env_config", &s)
+	if err == nil {
+		t.Error("no failure when missing required variable")
+	}
+}
+
+func TestBlankDefaultVar(t *testing.T) {
+	var s Specification
+	os.Clearenv()
+	os.Setenv("ENV_CONFIG_REQUIREDVAR", "requiredvalue")
+	if err := Process("env_config", &s); err != nil {
+		t.Error(err.Error())
+	}
+
+	if s.DefaultVar != "foobar" {
+		t.Errorf("expected %s, got %s", "foobar", s.DefaultVar)
+	}
+
+	if *s.SomePointerWithDefault != "foo2baz" {
+		t.Errorf("expected %s, got %s", "foo2baz", *s.SomePointerWithDefault)
+	}
+}
+
+func TestNonBlankDefaultVar(t *testing.T) {
+	var s Specification
+	os.Clearenv()
+	os.Setenv("ENV_CONFIG_DEFAULTVAR", "nondefaultval")
+	os.Setenv("ENV_CONFIG_REQUIREDVAR", "requiredvalue")
+	if err := Process("env_config", &s); err != nil {
+		t.Error(err.Error())
+	}
+
+	if s.DefaultVar != "nondefaultval" {
+		t.Errorf("expected %s, got %s", "nondefaultval", s.DefaultVar)
+	}
+}
+
+func TestExplicitBlankDefaultVar(t *testing.T) {
+	var s Specification
+	os.Clearenv()
+	os.Setenv("ENV_CONFIG_DEFAULTVAR", "")
+	os.Setenv("ENV_CONFIG_REQUIREDVAR", "")
+
+	if err := Process("env_config", &s); err != nil {
+		t.Error(err.Error())
+	}
+
+	if s.DefaultVar != "" {
+		t.Errorf("expected %s, got %s", "\"\"", s.DefaultVar)
+	}
+}
+
+func TestWithoutDefaults(t *testing.T) {
+	s := struct {
+		Host string `default:"localhost"`
+		Port int    `default:"8080"`
+	}{Host: "base.example.com", Port: 9000}
+	os.Clearenv()
+	os.Setenv("ENV_CONFIG_PORT", "9090")
+	if err := Process("env_config", &s, WithoutDefaults()); err != nil {
+		t.Fatal(err.Error())
+	}
+
+	if s.Host != "base.example.com" {
+		t.Errorf("expected %q, got %q", "base.example.com", s.Host)
+	}
+	if s.Port != 9090 {
+		t.Errorf("expected %d, got %d", 9090, s.Port)
+	}
+}
+
+func TestWithoutDefaultsRequired(t *testing.T) {
+	var s struct {
+		Host string `required:"true" default:"localhost"`
+	}
+	os.Clearenv()
+	err := Process("env_config", &s, WithoutDefaults())
+	if experr := "required key ENV_CONFIG_HOST missing value"; err == nil || err.Error() != experr {
+		t.Errorf("expected %s, got %v", experr, err)
+	}
+}
+
+func TestWithRequireAll(t *testing.T) {
+	var s struct {
+		Host    string
+		Port    int    `default:"80"`
+		User    string `envconfig:"SERVICE_USER"`
+		Debug   bool
+		Ignored string `ignored:"true"`
+	}
+	os.Clearenv()
+	os.Setenv("ENV_CONFIG_DEBUG", "false")
+	err := Process("env_config", &s, WithRequireAll())
+	if experr := "required keys ENV_CONFIG_HOST, SERVICE_USER missing value"; err == nil || err.Error() != experr {
+		t.Errorf("expected %s, got %v", experr, err)
+	}
+
+	os.Setenv("ENV_CONFIG_HOST", "localhost")
+	os.Setenv("SERVICE_USER", "kelsey")
+	if err := Process("env_config", &s, WithRequireAll()); err != nil {
+		t.Errorf("expected no error, got %v", err)
+	}
+}
+
+func TestKnownKeys(t *testing.T) {
+	var s struct {
+		Host    string `envconfig:"SERVICE_HOST"`
+		Port    int    `deprecated_name:"OLD_PORT" default:"80"`
+		Servers []*server
+		Extra   map[string]string `envconfig:",remainder"`
+	}
+	os.Clearenv()
+	keys, err := KnownKeys("env_config", &s, WithFallbackPrefixes("legacy"))
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	want := []string{
+		"ENV_CONFIG_*",
+		"ENV_CONFIG_PORT",
+		"ENV_CONFIG_SERVERS",
+		"ENV_CONFIG_SERVERS_N_HOST",
+		"ENV_CONFIG_SERVERS_N_PORT",
+		"ENV_CONFIG_SERVICE_HOST",
+		"LEGACY_PORT",
+		"LEGACY_SERVERS",
+		"LEGACY_SERVICE_HOST",
+		"OLD_PORT",
+		"SERVICE_HOST",
+	}
+	if !reflect.DeepEqual(keys, want) {
+		t.Errorf("expected %q, got %q", want, keys)
+	}
+	if s.Servers != nil {
+		t.Errorf("expected spec to be left untouched, got %v", s.Servers)
+	}
+}
+
+func TestWithSources(t *testing.T) {
+	var s struct {
+		Host   string `default:"localhost"`
+		Port   int    `default:"80"`
+		Pinned string `default:"fixed" sources:"default,env"`
+	}
+	os.Clearenv()
+	os.Setenv("ENV_CONFIG_HOST", "example.com")
+	os.Setenv("ENV_CONFIG_PINNED", "override")
+
+	tests := []struct {
+		opts         []Option
+		host, pinned string
+		port         int
+	}{
+		{nil, "example.com", "fixed", 80},
+		{[]Option{WithSources(SourceDefault, SourceEnv)}, "localhost", "fixed", 80},
+		{[]Option{WithSources(SourceEnv)}, "example.com", "fixed", 0},
+	}
+	for _, test := range tests {
+		s.Host, s.Port, s.Pinned = "", 0, ""
+		if err := Process("env_config", &s, test.opts...); err != nil {
+			t.Fatal(err.Error())
+		}
+		if s.Host != test.host {
+			t.Errorf("expected %s, got %s", test.host, s.Host)
+		}
+		if s.Port != test.port {
+			t.Errorf("expected %d, got %d", test.port, s.Port)
+		}
+		if s.Pinned != test.pinned {
+			t.Errorf("expected %s, got %s", test.pinned, s.Pinned)
+		}
+	}
+
+	var bad struct {
+		Host string `sources:"flag,env"`
+	}
+	err := Process("env_config", &bad)
+	if experr := `envconfig: unknown source "flag" for Host`; err == nil || err.Error() != experr {
+		t.Errorf("expected %s, got %v", experr, err)
+	}
+}
+
+func TestWithLogger(t *testing.T) {
+	var s struct {
+		Host     string
+		Port     int    `default:"80"`
+		Password string `secret:"true"`
+		Zone     string `envconfig:"SERVICE_ZONE"`
+		Debug    bool
+	}
+	os.Clearenv()
+	os.Setenv("ENV_CONFIG_HOST", "example.com")
+	os.Setenv("ENV_CONFIG_PASSWORD", "hunter2")
+	os.Setenv("SERVICE_ZONE", "eu")
+	buf := new(bytes.Buffer)
+	l := log.New(buf, "", 0)
+	if err := Process("env_config", &s, WithLogger(l)); err != nil {
+		t.Fatal(err.Error())
+	}
+	want := `envconfig: Host: checked ENV_CONFIG_HOST, using "example.com" from env
+envconfig: Port: checked ENV_CONFIG_PORT, using "80" from default
+envconfig: Password: checked ENV_CONFIG_PASSWORD, using "****" from env
+envconfig: Zone: checked ENV_CONFIG_SERVICE_ZONE, SERVICE_ZONE, using "eu" from alt
+envconfig: Debug: checked ENV_CONFIG_DEBUG, not found
+`
+	if got := buf.String(); got != want {
+		t.Errorf("expected %q, got %q", want, got)
+	}
+}
+
+func TestWithOnError(t *testing.T) {
+	var s struct {
+		Port    int
+		Timeout time.Duration `required:"true"`
+		Debug   bool
+	}
+	os.Clearenv()
+	os.Setenv("ENV_CONFIG_PORT", "eighty")
+	os.Setenv("ENV_CONFIG_DEBUG", "true")
+
+	var seen []string
+	tolerate := WithOnError(func(info VarInfo, err error) error {
+		seen = append(seen, info.Name)
+		return nil
+	})
+	if err := Process("env_config", &s, tolerate); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if !reflect.DeepEqual(seen, []string{"Port", "Timeout"}) {
+		t.Errorf("expected %v, got %v", []string{"Port", "Timeout"}, seen)
+	}
+	if s.Port != 0 || !s.Debug {
+		t.Errorf("expected %d and %v, got %d and %v", 0, true, s.Port, s.Debug)
+	}
+
+	errOptional := errors.New("optional field")
+	strict := WithOnError(func(info VarInfo, err error) error {
+		if info.Name == "Port" {
+			return fmt.Errorf("%w: %v", errOptional, err)
+		}
+		return nil
+	})
+	if err := Process("env_config", &s, strict); !errors.Is(err, errOptional) {
+		t.Errorf("expected %v, got %v", errOptional, err)
+	}
+
+	// under WithRequireAll too
+	os.Setenv("ENV_CONFIG_PORT", "80")
+	os.Setenv("ENV_CONFIG_TIMEOUT", "5s")
+	os.Unsetenv("ENV_CONFIG_DEBUG")
+	errUnset := errors.New("unset field")
+	wrap := WithOnError(func(info VarInfo, err error) error {
+		return fmt.Errorf("%w: %v", errUnset, err)
+	})
+	if err := Process("env_config", &s, WithRequireAll(), wrap); !errors.Is(err, errUnset) {
+		t.Errorf("expected %v, got %v", errUnset, err)
+	}
+}
+
+type specWithRegisteredDefaults struct {
+	Host string
+	Port int `default:"80"`
+	User string
+}
+
+func TestSetDefaults(t *testing.T) {
+	err := SetDefaults(&specWithRegisteredDefaults{}, map[string]string{
+		"Host": "localhost",
+		"Port": "8080",
+		"User": "nobody",
+	})
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	var s specWithRegisteredDefaults
+	os.Clearenv()
+	os.Setenv("ENV_CONFIG_USER", "kelsey")
+	if err := Process("env_config", &s); err != nil {
+		t.Fatal(err.Error())
+	}
+	if s.Host != "localhost" {
+		t.Errorf("expected %s, got %s", "localhost", s.Host)
+	}
+	if s.Port != 80 {
+		t.Errorf("expected %d, got %d", 80, s.Port)
+	}
+	if s.User != "kelsey" {
+		t.Errorf("expected %s, got %s", "kelsey", s.User)
+	}
+}
+
+type specWithNestedRegisteredDefaults struct {
+	Port int
+	DB   struct {
+		Port int
+		Host string
+	}
+}
+
+func TestSetDefaultsNested(t *testing.T) {
+	err := SetDefaults(&specWithNestedRegisteredDefaults{}, map[string]string{
+		"Port":    "9",
+		"DB.Host": "db.internal",
+	})
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	defer SetDefaults(&specWithNestedRegisteredDefaults{}, nil)
+
+	var s specWithNestedRegisteredDefaults
+	os.Clearenv()
+	if err := Process("env_config", &s); err != nil {
+		t.Fatal(err.Error())
+	}
+	if s.Port != 9 || s.DB.Port != 0 {
+		t.Errorf("expected %d and %d, got %d and %d", 9, 0, s.Port, s.DB.Port)
+	}
+	if s.DB.Host != "db.internal" {
+		t.Errorf("expected %s, got %s", "db.internal", s.DB.Host)
+	}
+}
+
+func TestSetDefaultsUnknownField(t *testing.T) {
+	err := SetDefaults(&specWithRegisteredDefaults{}, map[string]string{"Hots": "localhost"})
+	if experr := "envconfig: no field Hots for default value"; err == nil || err.Error() != experr {
+		t.Errorf("expected %s, got %v", experr, err)
+	}
+}
+
+func TestExpandedDefault(t *testing.T) {
+	var s struct {
+		Host    string `default:"${FALLBACK_HOST:-localhost}"`
+		Cache   string `default:"${CACHE_ROOT}/cache"`
+		Literal string `default:"pa$$word"`
+	}
+	tests := []struct {
+		env   map[string]string
+		host  string
+		cache string
+	}{
+		{nil, "localhost", "/cache"},
+		{map[string]string{"FALLBACK_HOST": "db.internal", "CACHE_ROOT": "/tmp"}, "db.internal", "/tmp/cache"},
+		{map[string]string{"FALLBACK_HOST": ""}, "localhost", "/cache"},
+		{map[string]string{"ENV_CONFIG_HOST": "explicit", "FALLBACK_HOST": "db.internal"}, "explicit", "/cache"},
+	}
+	for _, test := range tests {
+		os.Clearenv()
+		for k, v := range test.env {
+			os.Setenv(k, v)
+		}
+		if err := Process("env_config", &s); err != nil {
+			t.Fatal(err.Error())
+		}
+		if s.Host != test.host {
+			t.Errorf("expected %q, got %q", test.host, s.Host)
+		}
+		if s.Cache != test.cache {
+			t.Errorf("expected %q, got %q", test.cache, s.Cache)
+		}
+		if s.Literal != "pa$$word" {
+			t.Errorf("expected %q, got %q", "pa$$word", s.Literal)
+		}
+	}
+}
+
+func TestKeepDefaultOnEmpty(t *testing.T) {
+	var s struct {
+		Host    string `default:"localhost" keep_default_on_empty:"true"`
+		Path    string `default:"/var/lib" keep_default_on_empty:"true"`
+		Comment string `default:"none"`
+	}
+	os.Clearenv()
+	os.Setenv("ENV_CONFIG_HOST", "")
+	os.Setenv("ENV_CONFIG_PATH", "/srv")
+	os.Setenv("ENV_CONFIG_COMMENT", "")
+	if err := Process("env_config", &s); err != nil {
+		t.Fatal(err.Error())
+	}
+
+	if s.Host != "localhost" {
+		t.Errorf("expected %q, got %q", "localhost", s.Host)
+	}
+	if s.Path != "/srv" {
+		t.Errorf("expected %q, got %q", "/srv", s.Path)
+	}
+	if s.Comment != "" {
+		t.Errorf("expected %q, got %q", "", s.Comment)
+	}
+}
+
+type specWithDefaultValues struct {
+	Labels  map[string]string
+	Servers []string `default:"a,b"`
+	Port    int
+	Timeout time.Duration
+}
+
+func (s *specWithDefaultValues) DefaultValues() map[string]interface{} {
+	return map[string]interface{}{
+		"Labels":  map[string]string{"team": "core"},
+		"Servers": []string{"ignored"},
+		"Port":    8080,
+		"Timeout": time.Second,
+	}
+}
+
+func TestDefaultValuesMethod(t *testing.T) {
+	s := specWithDefaultValues{Timeout: time.Minute}
+	os.Clearenv()
+	os.Setenv("ENV_CONFIG_PORT", "9090")
+	if err := Process("env_config", &s); err != nil {
+		t.Fatal(err.Error())
+	}
+
+	if want := map[string]string{"team": "core"}; !reflect.DeepEqual(s.Labels, want) {
+		t.Errorf("expected %v, got %v", want, s.Labels)
+	}
+	if want := []string{"a", "b"}; !reflect.DeepEqual(s.Servers, want) {
+		t.Errorf("expected tag default %v, got %v", want, s.Servers)
+	}
+	if s.Port != 9090 {
+		t.Errorf("expected %d, got %d", 9090, s.Port)
+	}
+	if s.Timeout != time.Minute {
+		t.Errorf("expected existing value %s, got %s", time.Minute, s.Timeout)
+	}
+}
+
+type specWithNestedDefaultValues struct {
+	Port int
+	DB   struct {
+		Port int
+	}
+}
+
+func (s *specWithNestedDefaultValues) DefaultValues() map[string]interface{} {
+	return map[string]interface{}{"Port": 8080, "DB.Port": 5432}
+}
+
+func TestDefaultValuesMethodNested(t *testing.T) {
+	var s specWithNestedDefaultValues
+	os.Clearenv()
+	if err := Process("env_config", &s); err != nil {
+		t.Fatal(err.Error())
+	}
+	if s.Port != 8080 || s.DB.Port != 5432 {
+		t.Errorf("expected %d and %d, got %d and %d", 8080, 5432, s.Port, s.DB.Port)
+	}
+}
+
+type specWithBadDefaultValues struct {
+	Port int
+}
+
+func (s *specWithBadDefaultValues) DefaultValues() map[string]interface{} {
+	return map[string]interface{}{"Port": "8080"}
+}
+
+func TestDefaultValuesMethodTypeMismatch(t *testing.T) {
+	var s specWithBadDefaultValues
+	os.Clearenv()
+	err := Process("env_config", &s)
+	if experr := "envconfig: default value for Port is string, not int"; err == nil || err.Error() != experr {
+		t.Errorf("expected %s, got %v", experr, err)
+	}
+}
+
+func TestAlternateNameDefaultVar(t *testing.T) {
+	var s Specification
+	os.Clearenv()
+	os.Setenv("BROKER", "betterbroker")
+	os.Setenv("ENV_CONFIG_REQUIREDVAR", "foo")
+	if err := Process("env_config", &s); err != nil {
+		t.Error(err.Error())
+	}
+
+	if s.NoPrefixDefault != "betterbroker" {
+		t.Errorf("expected %q, got %q", "betterbroker", s.NoPrefixDefault)
+	}
+
+	os.Clearenv()
+	os.Setenv("ENV_CONFIG_REQUIREDVAR", "foo")
+	if err := Process("env_config", &s); err != nil {
+		t.Error(err.Error())
+	}
+
+	if s.NoPrefixDefault != "127.0.0.1" {
+		t.Errorf("expected %q, got %q", "127.0.0.1", s.NoPrefixDefault)
+	}
+}
+
+func TestRequiredDefault(t *testing.T) {
+	var s Specification
+	os.Clearenv()
+	os.Setenv("ENV_CONFIG_REQUIREDVAR", "foo")
+	if err := Process("env_config", &s); err != nil {
+		t.Error(err.Error())
+	}
+
+	if s.RequiredDefault != "foo2bar" {
+		t.Errorf("expected %q, got %q", "foo2bar", s.RequiredDefault)
+	}
+}
+
+func TestProcessAll(t *testing.T) {
+	var s struct {
+		Port    int
+		Debug   bool
+		Timeout time.Duration
+		Host    string `required:"true"`
+		Name    string
+	}
+	os.Clearenv()
+	os.Setenv("ENV_CONFIG_PORT", "eighty")
+	os.Setenv("ENV_CONFIG_DEBUG", "maybe")
+	os.Setenv("ENV_CONFIG_TIMEOUT", "soon")
+	os.Setenv("ENV_CONFIG_NAME", "app")
+	err := ProcessAll("env_config", &s)
+	v, ok := err.(*Errors)
+	if !ok {
+		t.Fatalf("expected *Errors, got %T %v", err, err)
+	}
+	if len(v.Errs) != 4 || v.Truncated {
+		t.Errorf("expected %d errors, got %d (truncated %v)", 4, len(v.Errs), v.Truncated)
+	}
+	if _, ok := v.Errs[0].(*ParseError); !ok {
+		t.Errorf("expected ParseError, got %T", v.Errs[0])
+	}
+	if experr := "required key ENV_CONFIG_HOST missing value"; len(v.Errs) == 4 && v.Errs[3].Error() != experr {
+		t.Errorf("expected %s, got %v", experr, v.Errs[3])
+	}
+	var pe *ParseError
+	if !errors.As(err, &pe) || pe.FieldName != "Port" {
+		t.Errorf("expected the ParseError of %s, got %v", "Port", pe)
+	}
+	if !reflect.DeepEqual(v.Unwrap(), v.Errs) {
+		t.Errorf("expected %v, got %v", v.Errs, v.Unwrap())
+	}
+	// the fields that parse are still assigned
+	if s.Name != "app" {
+		t.Errorf("expected %s, got %s", "app", s.Name)
+	}
+
+	err = ProcessAll("env_config", &s, WithMaxErrors(2))
+	v, ok = err.(*Errors)
+	if !ok {
+		t.Fatalf("expected *Errors, got %T %v", err, err)
+	}
+	if len(v.Errs) != 2 || !v.Truncated {
+		t.Errorf("expected %d errors, got %d (truncated %v)", 2, len(v.Errs), v.Truncated)
+	}
+	if msg := err.Error(); !strings.HasPrefix(msg, "2 errors: ") || !strings.HasSuffix(msg, " (stopped after 2 errors)") {
+		t.Errorf("expected a truncated summary, got %s", msg)
+	}
+
+	os.Setenv("ENV_CONFIG_PORT", "80")
+	os.Setenv("ENV_CONFIG_DEBUG", "true")
+	os.Setenv("ENV_CONFIG_TIMEOUT", "5s")
+	os.Setenv("ENV_CONFIG_HOST", "localhost")
+	if err := ProcessAll("env_config", &s, WithMaxErrors(2)); err != nil {
+		t.Errorf("expected no error, got %v", err)
+	}
+}
+
+func TestRequiredIf(t *testing.T) {
+	var s struct {
+		TLSEnabled  bool
+		TLSCertFile string `required_if:"TLSEnabled"`
+		Server      struct {
+			Debug   *bool
+			LogFile string `required_if:"Debug"`
+		}
+	}
+	os.Clearenv()
+	if err := Process("env_config", &s); err != nil {
+		t.Errorf("expected no error, got %v", err)
+	}
+
+	os.Setenv("ENV_CONFIG_TLSENABLED", "true")
+	err := Process("env_config", &s)
+	if experr := "required key ENV_CONFIG_TLSCERTFILE missing value"; err == nil || err.Error() != experr {
+		t.Errorf("expected %s, got %v", experr, err)
+	}
+
+	os.Setenv("ENV_CONFIG_TLSCERTFILE", "/etc/tls.crt")
+	os.Setenv("ENV_CONFIG_SERVER_DEBUG", "true")
+	err = Process("env_config", &s)
+	if experr := "required key ENV_CONFIG_SERVER_LOGFILE missing value"; err == nil || err.Error() != experr {
+		t.Errorf("expected %s, got %v", experr, err)
+	}
+
+	var bad struct {
+		Port     int
+		CertFile string `required_if:"Port"`
+	}
+	experr := "envconfig: required_if of CertFile refers to Port, which is not a bool"
+	if err := Process("env_config", &bad); err == nil || err.Error() != experr {
+		t.Errorf("expected %s, got %v", experr, err)
+	}
+	if err := ValidateSpec("env_config", &bad); err == nil || err.Error() != experr {
+		t.Errorf("expected %s, got %v", experr, err)
+	}
+	var unknown struct {
+		KeyFile string `required_if:"TLS"`
+	}
+	experr = "envconfig: required_if of KeyFile refers to unknown field TLS"
+	if err := Process("env_config", &unknown); err == nil || err.Error() != experr {
+		t.Errorf("expected %s, got %v", experr, err)
+	}
+}
+
+func TestWithRequiredVar(t *testing.T) {
+	var s struct {
+		Host   string
+		Port   int
+		Debug  bool
+		Name   string `required:"true"`
+		Server struct {
+			Addr string
+		}
+	}
+	os.Clearenv()
+	os.Setenv("ENV_CONFIG_NAME", "app")
+	// without the variable only the tags apply
+	if err := Process("env_config", &s, WithRequiredVar("ENV_CONFIG_REQUIRE")); err != nil {
+		t.Errorf("expected no error, got %v", err)
+	}
+
+	os.Setenv("ENV_CONFIG_REQUIRE", "host, ENV_CONFIG_PORT,SERVER_ADDR")
+	os.Setenv("ENV_CONFIG_HOST", "localhost")
+	os.Setenv("ENV_CONFIG_SERVER_ADDR", ":80")
+	err := Process("env_config", &s, WithRequiredVar("ENV_CONFIG_REQUIRE"))
+	if experr := "required key ENV_CONFIG_PORT missing value"; err == nil || err.Error() != experr {
+		t.Errorf("expected %s, got %v", experr, err)
+	}
+
+	os.Unsetenv("ENV_CONFIG_NAME")
+	os.Setenv("ENV_CONFIG_PORT", "8080")
+	err = Process("env_config", &s, WithRequiredVar("ENV_CONFIG_REQUIRE"))
+	if experr := "required key ENV_CONFIG_NAME missing value"; err == nil || err.Error() != experr {
+		t.Errorf("expected %s, got %v", experr, err)
+	}
+
+	os.Setenv("ENV_CONFIG_NAME", "app")
+	if err := CheckDisallowed("env_config", &s, WithRequiredVar("ENV_CONFIG_REQUIRE")); err != nil {
+		t.Errorf("expected no error, got %v", err)
+	}
+
+	os.Setenv("ENV_CONFIG_REQUIRE", "HOST,HOSTNAME")
+	err = Process("env_config", &s, WithRequiredVar("ENV_CONFIG_REQUIRE"))
+	if experr := "envconfig: ENV_CONFIG_REQUIRE lists unknown variable HOSTNAME"; err == nil || err.Error() != experr {
+		t.Errorf("expected %s, got %v", experr, err)
+	}
+}
+
+func TestRequiredIgnoresDefault(t *testing.T) {
+	var s struct {
+		Region string `required:"true" default:"us-east"`
+		Port   int    `default:"8080"`
+	}
+	os.Clearenv()
+	if err := Process("env_config", &s); err != nil {
+		t.Errorf("expected no error, got %v", err)
+	}
+	if s.Region != "us-east" || s.Port != 8080 {
+		t.Errorf("expected %s and %d, got %s and %d", "us-east", 8080, s.Region, s.Port)
+	}
+
+	err := Process("env_config", &s, WithRequiredIgnoresDefault())
+	if experr := "required key ENV_CONFIG_REGION missing value"; err == nil || err.Error() != experr {
+		t.Errorf("expected %s, got %v", experr, err)
+	}
+
+	// a default registered with SetDefaults does not satisfy it either
+	var registered struct {
+		Region string `required:"true"`
+	}
+	if err := SetDefaults(&registered, map[string]string{"Region": "us-east"}); err != nil {
+		t.Fatal(err.Error())
+	}
+	defer SetDefaults(&registered, nil)
+	err = Process("env_config", &registered, WithRequiredIgnoresDefault())
+	if experr := "required key ENV_CONFIG_REGION missing value"; err == nil || err.Error() != experr {
+		t.Errorf("expected %s, got %v", experr, err)
+	}
+
+	os.Setenv("ENV_CONFIG_REGION", "eu-west")
+	if err := Process("env_config", &s, WithRequiredIgnoresDefault()); err != nil {
+		t.Errorf("expected no error, got %v", err)
+	}
+	if s.Region != "eu-west" {
+		t.Errorf("expected %s, got %s", "eu-west", s.Region)
+	}
+}
+
+func TestPointerToPointerField(t *testing.T) {
+	var s struct {
+		Name      **string
+		Port      **int
+		Timeout   ***time.Duration
+		Unset     **int
+		Defaulted **bool `default:"true"`
+	}
+	os.Clearenv()
+	os.Setenv("ENV_CONFIG_NAME", "app")
+	os.Setenv("ENV_CONFIG_PORT", "8080")
+	os.Setenv("ENV_CONFIG_TIMEOUT", "5s")
+	if err := Process("env_config", &s); err != nil {
+		t.Fatal(err.Error())
+	}
+	if s.Name == nil || *s.Name == nil || **s.Name != "app" {
+		t.Errorf("expected %q, got %v", "app", s.Name)
+	}
+	if s.Port == nil || *s.Port == nil || **s.Port != 8080 {
+		t.Errorf("expected %d, got %v", 8080, s.Port)
+	}
+	if s.Timeout == nil || *s.Timeout == nil || **s.Timeout == nil || ***s.Timeout != 5*time.Second {
+		t.Errorf("expected %s, got %v", 5*time.Second, s.Timeout)
+	}
+	if s.Unset != nil {
+		t.Errorf("expected <nil>, got %v", s.Unset)
+	}
+	if s.Defaulted == nil || *s.Defaulted == nil || !**s.Defaulted {
+		t.Errorf("expected %v, got %v", true, s.Defaulted)
+	}
+}
+
+func TestPointerFieldBlank(t *testing.T) {
+	var s Specification
+	os.Clearenv()
+	os.Setenv("ENV_CONFIG_REQUIREDVAR", "foo")
+	if err := Process("env_config", &s); err != nil {
+		t.Error(err.Error())
+	}
+
+	if s.SomePointer != nil {
+		t.Errorf("expected <nil>, got %q", *s.SomePointer)
+	}
+}
+
+func TestPointerScalarDefaults(t *testing.T) {
+	var s struct {
+		Int         *int           `default:"5"`
+		Bool        *bool          `default:"true"`
+		Uint        *uint16        `default:"7"`
+		Float       *float64       `default:"0.5"`
+		Duration    *time.Duration `default:"3s"`
+		NoDefault   *int
+		BoolNoValue *bool
+	}
+	os.Clearenv()
+	if err := Process("env_config", &s); err != nil {
+		t.Fatal(err.Error())
+	}
+
+	if s.Int == nil || *s.Int != 5 {
+		t.Errorf("expected pointer to %d, got %v", 5, s.Int)
+	}
+	if s.Bool == nil || *s.Bool != true {
+		t.Errorf("expected pointer to %v, got %v", true, s.Bool)
+	}
+	if s.Uint == nil || *s.Uint != 7 {
+		t.Errorf("expected pointer to %d, got %v", 7, s.Uint)
+	}
+	if s.Float == nil || *s.Float != 0.5 {
+		t.Errorf("expected pointer to %f, got %v", 0.5, s.Float)
+	}
+	if s.Duration == nil || *s.Duration != 3*time.Second {
+		t.Errorf("expected pointer to %s, got %v", 3*time.Second, s.Duration)
+	}
+	if s.NoDefault != nil {
+		t.Errorf("expected <nil>, got %d", *s.NoDefault)
+	}
+	if s.BoolNoValue != nil {
+		t.Errorf("expected <nil>, got %v", *s.BoolNoValue)
+	}
+}
+
+func TestPointerScalarValues(t *testing.T) {
+	var s struct {
+		Int  *int  `default:"5"`
+		Bool *bool `default:"true"`
+	}
+	os.Clearenv()
+	os.Setenv("ENV_CONFIG_INT", "42")
+	os.Setenv("ENV_CONFIG_BOOL", "false")
+	if err := Process("env_config", &s); err != nil {
+		t.Fatal(err.Error())
+	}
+
+	if s.Int == nil || *s.Int != 42 {
+		t.Errorf("expected pointer to %d, got %v", 42, s.Int)
+	}
+	if s.Bool == nil || *s.Bool != false {
+		t.Errorf("expected pointer to %v, got %v", false, s.Bool)
+	}
+}
+
+func TestEmptyMapFieldOverride(t *testing.T) {
+	var s Specification
+	os.Clearenv()
+	os.Setenv("ENV_CONFIG_REQUIREDVAR", "foo")
+	os.Setenv("ENV_CONFIG_MAPFIELD", "")
+	if err := Process("env_config", &s); err != nil {
+		t.Error(err.Error())
+	}
+
+	if s.MapField == nil {
+		t.Error("expected empty map, got <nil>")
+	}
+
+	if len(s.MapField) != 0 {
+		t.Errorf("expected empty map, got map of size %d", len(s.MapField))
+	}
+}
+
+func TestQueryField(t *testing.T) {
+	var s struct {
+		Params  map[string][]string `query:"true"`
+		Filters url.Values          `query:"true"`
+	}
+	os.Clearenv()
+	os.Setenv("ENV_CONFIG_PARAMS", "a=1&b=2&b=3")
+	os.Setenv("ENV_CONFIG_FILTERS", "name=J%C3%BCrgen&tag=a+b")
+	if err := Process("env_config", &s); err != nil {
+		t.Fatal(err.Error())
+	}
+
+	want := map[string][]string{"a": {"1"}, "b": {"2", "3"}}
+	if !reflect.DeepEqual(s.Params, want) {
+		t.Errorf("expected %#v, got %#v", want, s.Params)
+	}
+	if got := s.Filters.Get("name"); got != "Jürgen" {
+		t.Errorf("expected %q, got %q", "Jürgen", got)
+	}
+	if got := s.Filters.Get("tag"); got != "a b" {
+		t.Errorf("expected %q, got %q", "a b", got)
+	}
+}
+
+func TestQueryFieldError(t *testing.T) {
+	var s struct {
+		Params url.Values `query:"true"`
+	}
+	os.Clearenv()
+	os.Setenv("ENV_CONFIG_PARAMS", "a=%zz")
+	err := Process("env_config", &s)
+	v, ok := err.(*ParseError)
+	if !ok {
+		t.Fatalf("expected ParseError, got %T %v", err, err)
+	}
+	if v.FieldName != "Params" {
+		t.Errorf("expected %s, got %v", "Params", v.FieldName)
+	}
+}
+
+func TestSeparator(t *testing.T) {
+	var s struct {
+		Ports  []int             `sep:";"`
+		Paths  []string          `sep:";"`
+		Drives map[string]string `sep:";" mapsep:"="`
+	}
+	os.Clearenv()
+	os.Setenv("ENV_CONFIG_PORTS", "80;443")
+	os.Setenv("ENV_CONFIG_PATHS", `C:\Program Files\a,b;D:\data`)
+	os.Setenv("ENV_CONFIG_DRIVES", `system=C:\;data=D:\,backup`)
+	if err := Process("env_config", &s); err != nil {
+		t.Fatal(err.Error())
+	}
+	if want := []int{80, 443}; !reflect.DeepEqual(s.Ports, want) {
+		t.Errorf("expected %v, got %v", want, s.Ports)
+	}
+	if want := []string{`C:\Program Files\a,b`, `D:\data`}; !reflect.DeepEqual(s.Paths, want) {
+		t.Errorf("expected %q, got %q", want, s.Paths)
+	}
+	if want := map[string]string{"system": `C:\`, "data": `D:\,backup`}; !reflect.DeepEqual(s.Drives, want) {
+		t.Errorf("expected %q, got %q", want, s.Drives)
+	}
+	infos, err := gatherInfo("env_config", &s)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	desc := toTypeDescription(infos[0].Field.Type(), infos[0].Tags)
+	if want := "Semicolon-separated list of Integer"; desc != want {
+		t.Errorf("expected %s, got %s", want, desc)
+	}
+
+	var bad struct {
+		Ports []int `sep:"; "`
+	}
+	err = Process("env_config", &bad)
+	v, ok := err.(*ParseError)
+	if !ok {
+		t.Fatalf("expected ParseError, got %T %v", err, err)
+	}
+	if experr := `invalid sep "; "`; v.Err.Error() != experr {
+		t.Errorf("expected %s, got %v", experr, v.Err)
+	}
+}
+
+func TestMapSeparatorAliases(t *testing.T) {
+	var s struct {
+		Mirrors map[string]string `kv_sep:"="`
+		Weights map[string]int    `map_sep:";" kv_sep:"="`
+	}
+	os.Clearenv()
+	os.Setenv("ENV_CONFIG_MIRRORS", "a=http://x,b=http://y")
+	os.Setenv("ENV_CONFIG_WEIGHTS", "a=1;b=2")
+	if err := Process("env_config", &s); err != nil {
+		t.Fatal(err.Error())
+	}
+	if want := map[string]string{"a": "http://x", "b": "http://y"}; !reflect.DeepEqual(s.Mirrors, want) {
+		t.Errorf("expected %v, got %v", want, s.Mirrors)
+	}
+	if want := map[string]int{"a": 1, "b": 2}; !reflect.DeepEqual(s.Weights, want) {
+		t.Errorf("expected %v, got %v", want, s.Weights)
+	}
+
+	infos, err := gatherInfo("env_config", &s)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	desc := toTypeDescription(infos[1].Field.Type(), infos[1].Tags)
+	if want := "Semicolon-separated list of String=Integer pairs"; desc != want {
+		t.Errorf("expected %s, got %s", want, desc)
+	}
+
+	os.Setenv("ENV_CONFIG_MIRRORS", "a=http://x?q=1")
+	err = Process("env_config", &s)
+	v, ok := err.(*ParseError)
+	if !ok {
+		t.Fatalf("expected ParseError, got %T %v", err, err)
+	}
+	if experr := `invalid map item: "a=http://x?q=1"`; v.Err.Error() != experr {
+		t.Errorf("expected %s, got %v", experr, v.Err)
+	}
+}
+
+func TestForgivingNumbers(t *testing.T) {
+	var s struct {
+		Port  int
+		Mask  uint8
+		Ratio float64
+		Debug bool
+	}
+	os.Clearenv()
+	os.Setenv("ENV_CONFIG_PORT", "+8080")
+	os.Setenv("ENV_CONFIG_MASK", " 0x1F ")
+	os.Setenv("ENV_CONFIG_RATIO", " +1.5")
+	os.Setenv("ENV_CONFIG_DEBUG", " true\n")
+	if err := Process("env_config", &s); err != nil {
+		t.Fatal(err.Error())
+	}
+	if s.Port != 8080 {
+		t.Errorf("expected %d, got %d", 8080, s.Port)
+	}
+	if s.Mask != 0x1F {
+		t.Errorf("expected %d, got %d", 0x1F, s.Mask)
+	}
+	if s.Ratio != 1.5 {
+		t.Errorf("expected %v, got %v", 1.5, s.Ratio)
+	}
+	if !s.Debug {
+		t.Errorf("expected %t, got %t", true, s.Debug)
+	}
+
+	os.Setenv("ENV_CONFIG_PORT", " 42 ")
+	if err := Process("env_config", &s); err != nil {
+		t.Fatal(err.Error())
+	}
+	if s.Port != 42 {
+		t.Errorf("expected %d, got %d", 42, s.Port)
+	}
+
+	for _, value := range []string{"4 2", "++42", "+-42", "+"} {
+		os.Setenv("ENV_CONFIG_PORT", value)
+		err := Process("env_config", &s)
+		if _, ok := err.(*ParseError); !ok {
+			t.Errorf("%q: expected ParseError, got %T %v", value, err, err)
+		}
+	}
+}
+
+func TestFiniteFloat(t *testing.T) {
+	var s struct {
+		Permissive float64
+		Finite     float64   `finite:"true"`
+		Ratios     []float32 `finite:"true"`
+	}
+	tests := []struct {
+		value  string
+		experr string
+	}{
+		{"1.5", ""},
+		{"Inf", `value "Inf" is not a finite number`},
+		{"-inf", `value "-inf" is not a finite number`},
+		{"NaN", `value "NaN" is not a finite number`},
+	}
+	for _, test := range tests {
+		os.Clearenv()
+		os.Setenv("ENV_CONFIG_PERMISSIVE", test.value)
+		if err := Process("env_config", &s); err != nil {
+			t.Fatalf("%s: %v", test.value, err)
+		}
+		if want, _ := strconv.ParseFloat(test.value, 64); s.Permissive != want && !math.IsNaN(want) {
+			t.Errorf("expected %v, got %v", want, s.Permissive)
+		}
+
+		for _, key := range []string{"ENV_CONFIG_FINITE", "ENV_CONFIG_RATIOS"} {
+			os.Clearenv()
+			os.Setenv(key, test.value)
+			err := Process("env_config", &s)
+			if test.experr == "" {
+				if err != nil {
+					t.Errorf("%s: expected no error, got %v", key, err)
+				}
+				continue
+			}
+			v, ok := err.(*ParseError)
+			if !ok {
+				t.Fatalf("expected ParseError, got %T %v", err, err)
+			}
+			experr := test.experr
+			if key == "ENV_CONFIG_RATIOS" {
+				experr = "element 0: " + experr
+			}
+			if v.Err.Error() != experr {
+				t.Errorf("expected %s, got %v", experr, v.Err)
+			}
+		}
+	}
+	if s.Finite != 1.5 {
+		t.Errorf("expected %v, got %v", 1.5, s.Finite)
+	}
+}
+
+func TestTrimElements(t *testing.T) {
+	var s struct {
+		Verbatim []string
+		Trimmed  []string `trim_elements:"true"`
+		Skipped  []string `trim_elements:"true" skip_empty:"true"`
+		Ports    []int    `trim_elements:"true"`
+	}
+	os.Clearenv()
+	os.Setenv("ENV_CONFIG_VERBATIM", "a, b")
+	os.Setenv("ENV_CONFIG_TRIMMED", "a, b ,\tc")
+	os.Setenv("ENV_CONFIG_SKIPPED", "a, , b")
+	os.Setenv("ENV_CONFIG_PORTS", "80, 443")
+	if err := Process("env_config", &s); err != nil {
+		t.Fatal(err.Error())
+	}
+	if want := []string{"a", " b"}; !reflect.DeepEqual(s.Verbatim, want) {
+		t.Errorf("expected %q, got %q", want, s.Verbatim)
+	}
+	if want := []string{"a", "b", "c"}; !reflect.DeepEqual(s.Trimmed, want) {
+		t.Errorf("expected %q, got %q", want, s.Trimmed)
+	}
+	if want := []string{"a", "b"}; !reflect.DeepEqual(s.Skipped, want) {
+		t.Errorf("expected %q, got %q", want, s.Skipped)
+	}
+	if want := []int{80, 443}; !reflect.DeepEqual(s.Ports, want) {
+		t.Errorf("expected %v, got %v", want, s.Ports)
+	}
+}
+
+func TestSkipEmpty(t *testing.T) {
+	var s struct {
+		Kept    []string
+		Skipped []string `skip_empty:"true"`
+	}
+	tests := []struct {
+		value   string
+		kept    []string
+		skipped []string
+	}{
+		{",a,b", []string{"", "a", "b"}, []string{"a", "b"}},
+		{"a,b,", []string{"a", "b", ""}, []string{"a", "b"}},
+		{"a,,b", []string{"a", "", "b"}, []string{"a", "b"}},
+		{",,", []string{"", "", ""}, []string{}},
+	}
+	for _, test := range tests {
+		os.Clearenv()
+		os.Setenv("ENV_CONFIG_KEPT", test.value)
+		os.Setenv("ENV_CONFIG_SKIPPED", test.value)
+		if err := Process("env_config", &s); err != nil {
+			t.Fatal(err.Error())
+		}
+		if !reflect.DeepEqual(s.Kept, test.kept) {
+			t.Errorf("%q: expected %q, got %q", test.value, test.kept, s.Kept)
+		}
+		if !reflect.DeepEqual(s.Skipped, test.skipped) {
+			t.Errorf("%q: expected %q, got %q", test.value, test.skipped, s.Skipped)
+		}
+	}
+}
+
+func TestFromFile(t *testing.T) {
+	f, err := ioutil.TempFile("", "envconfig")
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	defer os.Remove(f.Name())
+	f.WriteString("s3cr3t")
+	f.Close()
+
+	var s struct {
+		Password string `from_file:"true"`
+		Token    string `from_file:"true" max_bytes:"4"`
+		Key      string `from_file:"true" max_bytes:"6"`
+	}
+	os.Clearenv()
+	os.Setenv("ENV_CONFIG_PASSWORD", f.Name())
+	os.Setenv("ENV_CONFIG_KEY", f.Name())
+	if err := Process("env_config", &s); err != nil {
+		t.Fatal(err.Error())
+	}
+	if s.Password != "s3cr3t" {
+		t.Errorf("expected %s, got %s", "s3cr3t", s.Password)
+	}
+	if s.Key != "s3cr3t" {
+		t.Errorf("expected %s, got %s", "s3cr3t", s.Key)
+	}
+
+	os.Setenv("ENV_CONFIG_TOKEN", f.Name())
+	err = Process("env_config", &s)
+	v, ok := err.(*ParseError)
+	if !ok {
+		t.Fatalf("expected ParseError, got %T %v", err, err)
+	}
+	if experr := fmt.Sprintf("file %s exceeds 4 bytes", f.Name()); v.Err.Error() != experr {
+		t.Errorf("expected %s, got %v", experr, v.Err)
+	}
+}
+
+func TestDeprecatedName(t *testing.T) {
+	var s struct {
+		Host string `deprecated_name:"ENV_CONFIG_SERVER"`
+	}
+	var buf bytes.Buffer
+	log.SetOutput(&buf)
+	defer log.SetOutput(os.Stderr)
+
+	tests := []struct {
+		env    map[string]string
+		host   string
+		notice bool
+	}{
+		{map[string]string{"ENV_CONFIG_SERVER": "old"}, "old", true},
+		{map[string]string{"ENV_CONFIG_HOST": "new"}, "new", false},
+		{map[string]string{"ENV_CONFIG_HOST": "new", "ENV_CONFIG_SERVER": "old"}, "new", false},
+	}
+	for _, test := range tests {
+		os.Clearenv()
+		buf.Reset()
+		s.Host = ""
+		for k, v := range test.env {
+			os.Setenv(k, v)
+		}
+		if err := Process("env_config", &s); err != nil {
+			t.Fatal(err.Error())
+		}
+		if s.Host != test.host {
+			t.Errorf("expected %s, got %s", test.host, s.Host)
+		}
+		notice := strings.Contains(buf.String(), "ENV_CONFIG_SERVER is deprecated, use ENV_CONFIG_HOST instead")
+		if notice != test.notice {
+			t.Errorf("%v: expected notice %v, got %q", test.env, test.notice, buf.String())
+		}
+		if err := CheckDisallowed("env_config", &s); err != nil {
+			t.Errorf("expected no error, got %v", err)
+		}
+	}
+}
+
+func TestRuneSlice(t *testing.T) {
+	var s struct {
+		Charset []rune `runes:"true"`
+		Ports   []int32
+	}
+	os.Clearenv()
+	os.Setenv("ENV_CONFIG_CHARSET", "aé,日本")
+	os.Setenv("ENV_CONFIG_PORTS", "1,2,3")
+	if err := Process("env_config", &s); err != nil {
+		t.Fatal(err.Error())
+	}
+	if want := []rune{'a', 'é', ',', '日', '本'}; !reflect.DeepEqual(s.Charset, want) {
+		t.Errorf("expected %q, got %q", want, s.Charset)
+	}
+	// without the tag, []rune is the same type as []int32 and is split
+	if want := []int32{1, 2, 3}; !reflect.DeepEqual(s.Ports, want) {
+		t.Errorf("expected %v, got %v", want, s.Ports)
+	}
+}
+
+func TestNormalizedPrefix(t *testing.T) {
+	var s struct {
+		Port int
+	}
+	tests := []struct {
+		prefix string
+		key    string
+	}{
+		{"app_", "APP_PORT"},
+		{"App_", "APP_PORT"},
+		{"APP", "APP_PORT"},
+		{"", "PORT"},
+	}
+	for _, test := range tests {
+		os.Clearenv()
+		os.Setenv(test.key, "8080")
+		s.Port = 0
+		if err := Process(test.prefix, &s); err != nil {
+			t.Fatal(err.Error())
+		}
+		if s.Port != 8080 {
+			t.Errorf("%q: expected %d, got %d", test.prefix, 8080, s.Port)
+		}
+		if err := CheckDisallowed(test.prefix, &s); err != nil {
+			t.Errorf("%q: expected no error, got %v", test.prefix, err)
+		}
+		report, err := Inspect(test.prefix, &s)
+		if err != nil {
+			t.Fatal(err.Error())
+		}
+		if report.Fields[0].Key != test.key || len(report.Unused) != 0 {
+			t.Errorf("%q: expected %s and no unused keys, got %s and %v", test.prefix, test.key, report.Fields[0].Key, report.Unused)
+		}
+	}
+}
+
+func TestNumericBool(t *testing.T) {
+	var s struct {
+		Enabled bool `boolstyle:"numeric"`
+	}
+	tests := []struct {
+		value string
+		want  bool
+		err   bool
+	}{
+		{"0", false, false},
+		{"1", true, false},
+		{"42", true, false},
+		{"-1", true, false},
+		{"abc", false, true},
+		{"true", false, true},
+	}
+	for _, test := range tests {
+		os.Clearenv()
+		os.Setenv("ENV_CONFIG_ENABLED", test.value)
+		s.Enabled = false
+		err := Process("env_config", &s)
+		if test.err {
+			if _, ok := err.(*ParseError); !ok {
+				t.Errorf("%q: expected ParseError, got %T %v", test.value, err, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatal(err.Error())
+		}
+		if s.Enabled != test.want {
+			t.Errorf("%q: expected %v, got %v", test.value, test.want, s.Enabled)
+		}
+	}
+}
+
+func TestBigNumberContainers(t *testing.T) {
+	var s struct {
+		Weights []*big.Int
+		Rates   map[string]*big.Float
+	}
+	os.Clearenv()
+	os.Setenv("ENV_CONFIG_WEIGHTS", "1,123456789012345678901234567890")
+	os.Setenv("ENV_CONFIG_RATES", "low:0.5,high:1e40")
+	if err := Process("env_config", &s); err != nil {
+		t.Fatal(err.Error())
+	}
+	want, _ := new(big.Int).SetString("123456789012345678901234567890", 10)
+	if len(s.Weights) != 2 || s.Weights[0].Int64() != 1 || s.Weights[1].Cmp(want) != 0 {
+		t.Errorf("expected %v, got %v", []*big.Int{big.NewInt(1), want}, s.Weights)
+	}
+	if len(s.Rates) != 2 || s.Rates["low"].String() != "0.5" || s.Rates["high"].String() != "1e+40" {
+		t.Errorf("expected %v, got %v", "map[high:1e+40 low:0.5]", s.Rates)
+	}
+
+	os.Setenv("ENV_CONFIG_WEIGHTS", "1,x")
+	err := Process("env_config", &s)
+	if v, ok := err.(*ParseError); !ok {
+		t.Errorf("expected ParseError, got %T %v", err, err)
+	} else if v.FieldName != "Weights" {
+		t.Errorf("expected %s, got %v", "Weights", v.FieldName)
+	}
+}
+
+func TestAppendable(t *testing.T) {
+	var s struct {
+		Hosts []string `appendable:"true" default:"localhost,127.0.0.1"`
+		Ports []int    `appendable:"true" sep:";"`
+	}
+	tests := []struct {
+		hosts, ports string
+		wantHosts    []string
+		wantPorts    []int
+	}{
+		{"+example.com", "+80", []string{"localhost", "127.0.0.1", "example.com"}, []int{80}},
+		{"example.com", "80;443", []string{"example.com"}, []int{80, 443}},
+		{"+", "+", []string{"localhost", "127.0.0.1"}, []int{}},
+	}
+	for _, test := range tests {
+		os.Clearenv()
+		os.Setenv("ENV_CONFIG_HOSTS", test.hosts)
+		os.Setenv("ENV_CONFIG_PORTS", test.ports)
+		s.Hosts, s.Ports = nil, nil
+		if err := Process("env_config", &s); err != nil {
+			t.Fatal(err.Error())
+		}
+		if !reflect.DeepEqual(s.Hosts, test.wantHosts) {
+			t.Errorf("expected %v, got %v", test.wantHosts, s.Hosts)
+		}
+		if !reflect.DeepEqual(s.Ports, test.wantPorts) {
+			t.Errorf("expected %v, got %v", test.wantPorts, s.Ports)
+		}
+	}
+}
+
+func TestProcessJSON(t *testing.T) {
+	var s struct {
+		Host string
+		Port int
+		User string
+	}
+	os.Clearenv()
+	os.Setenv("APP_CONFIG", `{"Host":"example.com","Port":8080,"User":"blob"}`)
+	os.Setenv("APP_USER", "env")
+	if err := ProcessJSON("APP_CONFIG", &s); err != nil {
+		t.Fatal(err.Error())
+	}
+	if err := Process("app", &s, WithoutDefaults()); err != nil {
+		t.Fatal(err.Error())
+	}
+	if s.Host != "example.com" {
+		t.Errorf("expected %s, got %s", "example.com", s.Host)
+	}
+	if s.Port != 8080 {
+		t.Errorf("expected %d, got %d", 8080, s.Port)
+	}
+	if s.User != "env" {
+		t.Errorf("expected %s, got %s", "env", s.User)
+	}
+
+	os.Unsetenv("APP_CONFIG")
+	if err := ProcessJSON("APP_CONFIG", &s); err != nil {
+		t.Errorf("expected no error, got %v", err)
+	}
+
+	os.Setenv("APP_CONFIG", `{"Port":"eighty"}`)
+	err := ProcessJSON("APP_CONFIG", &s)
+	v, ok := err.(*ParseError)
+	if !ok {
+		t.Fatalf("expected ParseError, got %T %v", err, err)
+	}
+	if v.KeyName != "APP_CONFIG" {
+		t.Errorf("expected %s, got %v", "APP_CONFIG", v.KeyName)
+	}
+}
+
+func TestStrictTags(t *testing.T) {
+	var valid struct {
+		Host string `json:"host" yaml:"host" bson:"host" default:"localhost"`
+		Port int    `envconfig:"PORT" xml:"port" validate:"required"`
+	}
+	os.Clearenv()
+	if err := Process("env_config", &valid, WithStrictTags()); err != nil {
+		t.Errorf("expected no error, got %v", err)
+	}
+
+	tests := []struct {
+		spec   interface{}
+		experr string
+	}{
+		{
+			&struct {
+				Debug bool `requird:"true"`
+			}{},
+			`envconfig: Debug: unknown tag "requird", did you mean "required"?`,
+		},
+		{
+			&struct {
+				Host string `json:"host" defualt:"localhost"`
+			}{},
+			`envconfig: Host: unknown tag "defualt", did you mean "default"?`,
+		},
+		{
+			&struct {
+				Hosts []string `spe:";"`
+			}{},
+			`envconfig: Hosts: unknown tag "spe", did you mean "sep"?`,
+		},
+	}
+	for _, test := range tests {
+		err := Process("env_config", test.spec, WithStrictTags())
+		if err == nil || err.Error() != test.experr {
+			t.Errorf("expected %s, got %v", test.experr, err)
+		}
+		// without the option the tag is ignored
+		if err := Process("env_config", test.spec); err != nil {
+			t.Errorf("expected no error, got %v", err)
+		}
+	}
+}
+
+func TestAltPrecedence(t *testing.T) {
+	var s struct {
+		Zone string `envconfig:"SERVICE_ZONE"`
+	}
+	os.Clearenv()
+	os.Setenv("ENV_CONFIG_SERVICE_ZONE", "new")
+	os.Setenv("SERVICE_ZONE", "old")
+
+	tests := []struct {
+		opts []Option
+		want string
+	}{
+		{nil, "new"},
+		{[]Option{WithAltPrecedence(false)}, "new"},
+		{[]Option{WithAltPrecedence(true)}, "old"},
+	}
+	for _, test := range tests {
+		if err := Process("env_config", &s, test.opts...); err != nil {
+			t.Fatal(err.Error())
+		}
+		if s.Zone != test.want {
+			t.Errorf("expected %s, got %s", test.want, s.Zone)
+		}
+	}
+}
+
+func TestProcessPrefixes(t *testing.T) {
+	var s struct {
+		Host string
+		Port int
+		User string `default:"nobody"`
+		Zone string `envconfig:"SERVICE_ZONE"`
+	}
+	os.Clearenv()
+	os.Setenv("APP_HOST", "new")
+	os.Setenv("LEGACY_HOST", "old")
+	os.Setenv("LEGACY_PORT", "8080")
+	os.Setenv("SERVICE_ZONE", "eu")
+	if err := ProcessPrefixes(&s, "app", "legacy"); err != nil {
+		t.Fatal(err.Error())
+	}
+	if s.Host != "new" {
+		t.Errorf("expected %s, got %s", "new", s.Host)
+	}
+	if s.Port != 8080 {
+		t.Errorf("expected %d, got %d", 8080, s.Port)
+	}
+	if s.User != "nobody" {
+		t.Errorf("expected %s, got %s", "nobody", s.User)
+	}
+	if s.Zone != "eu" {
+		t.Errorf("expected %s, got %s", "eu", s.Zone)
+	}
+
+	if err := CheckDisallowed("app", &s, WithFallbackPrefixes("legacy")); err != nil {
+		t.Errorf("expected no error, got %v", err)
+	}
+	os.Setenv("LEGACY_PASSWORD", "x")
+	experr := "unknown environment variable LEGACY_PASSWORD"
+	if err := CheckDisallowed("app", &s, WithFallbackPrefixes("legacy")); err == nil || err.Error() != experr {
+		t.Errorf("expected %s, got %v", experr, err)
+	}
+}
+
+type specWithComputed struct {
+	Scheme  string `default:"https"`
+	Host    string
+	BaseURL string `ignored:"true"`
+}
+
+func (s *specWithComputed) SetComputed() {
+	s.BaseURL = s.Scheme + "://" + s.Host
+}
+
+func TestComputer(t *testing.T) {
+	var s specWithComputed
+	os.Clearenv()
+	os.Setenv("ENV_CONFIG_HOST", "example.com")
+	if err := Process("env_config", &s); err != nil {
+		t.Fatal(err.Error())
+	}
+	if s.BaseURL != "https://example.com" {
+		t.Errorf("expected %s, got %s", "https://example.com", s.BaseURL)
+	}
+}
+
+func TestMapOfSlices(t *testing.T) {
+	var s struct {
+		Weights map[string][]int
+		Routes  map[string][]string `sep:";" mapsep:"=" listsep:" "`
+		Hosts   []string            `sep:" "`
+	}
+	os.Clearenv()
+	os.Setenv("ENV_CONFIG_WEIGHTS", "a:1|2|3,b:4|5")
+	os.Setenv("ENV_CONFIG_ROUTES", "api=10.0.0.1 10.0.0.2;web=10.0.0.3")
+	os.Setenv("ENV_CONFIG_HOSTS", "a,b c")
+	if err := Process("env_config", &s); err != nil {
+		t.Fatal(err.Error())
+	}
+	if want := map[string][]int{"a": {1, 2, 3}, "b": {4, 5}}; !reflect.DeepEqual(s.Weights, want) {
+		t.Errorf("expected %v, got %v", want, s.Weights)
+	}
+	if want := map[string][]string{"api": {"10.0.0.1", "10.0.0.2"}, "web": {"10.0.0.3"}}; !reflect.DeepEqual(s.Routes, want) {
+		t.Errorf("expected %v, got %v", want, s.Routes)
+	}
+	if want := []string{"a,b", "c"}; !reflect.DeepEqual(s.Hosts, want) {
+		t.Errorf("expected %v, got %v", want, s.Hosts)
+	}
+}
+
+func TestMapOfSlicesError(t *testing.T) {
+	var s struct {
+		Weights map[string][]int
+	}
+	tests := []struct {
+		value  string
+		experr string
+	}{
+		{"a:1|x,b:4", `map item "a:1|x": element 1: strconv.ParseInt: parsing "x": invalid syntax`},
+		{"a:1,b", `invalid map item: "b"`},
+	}
+	for _, test := range tests {
+		os.Clearenv()
+		os.Setenv("ENV_CONFIG_WEIGHTS", test.value)
+		err := Process("env_config", &s)
+		v, ok := err.(*ParseError)
+		if !ok {
+			t.Fatalf("expected ParseError, got %T %v", err, err)
+		}
+		if v.Err.Error() != test.experr {
+			t.Errorf("expected %s, got %v", test.experr, v.Err)
+		}
+	}
+}
+
+func TestProcessReport(t *testing.T) {
+	var s struct {
+		Host     string `default:"localhost"`
+		Port     int
+		Zone     string `envconfig:"SERVICE_ZONE"`
+		Password string `secret:"true"`
+		Debug    bool
+	}
+	os.Clearenv()
+	os.Setenv("ENV_CONFIG_PORT", "8080")
+	os.Setenv("SERVICE_ZONE", "eu")
+	os.Setenv("ENV_CONFIG_PASSWORD", "hunter2")
+	results, err := ProcessReport("env_config", &s)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	want := []FieldResult{
+		{Key: "ENV_CONFIG_HOST", FieldName: "Host", Value: "localhost", Source: SourceDefault},
+		{Key: "ENV_CONFIG_PORT", FieldName: "Port", Value: "8080", Source: SourceEnv},
+		{Key: "ENV_CONFIG_SERVICE_ZONE", FieldName: "Zone", Value: "eu", Source: SourceAlt},
+		{Key: "ENV_CONFIG_PASSWORD", FieldName: "Password", Value: "****", Source: SourceEnv},
+		{Key: "ENV_CONFIG_DEBUG", FieldName: "Debug", Source: SourceUnset},
+	}
+	if !reflect.DeepEqual(results, want) {
+		t.Errorf("expected %+v, got %+v", want, results)
+	}
+	if s.Host != "localhost" || s.Port != 8080 {
+		t.Errorf("expected %s and %d, got %s and %d", "localhost", 8080, s.Host, s.Port)
+	}
+}
+
+func TestProcessWithSnapshot(t *testing.T) {
+	var s struct {
+		Host     string `default:"${FALLBACK_HOST:-localhost}"`
+		Port     int
+		Zone     string `envconfig:"SERVICE_ZONE"`
+		Password string `secret:"true"`
+		Token    string `secret:"true" default:"${VAULT_TOKEN}"`
+		Debug    bool
+	}
+	os.Clearenv()
+	os.Setenv("VAULT_TOKEN", "s3cr3t")
+	os.Setenv("FALLBACK_HOST", "db.internal")
+	os.Setenv("ENV_CONFIG_PORT", "8080")
+	os.Setenv("SERVICE_ZONE", "eu")
+	os.Setenv("ENV_CONFIG_PASSWORD", "hunter2")
+	os.Setenv("UNRELATED", "x")
+	snapshot, err := ProcessWithSnapshot("env_config", &s)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	want := map[string]string{
+		"FALLBACK_HOST":       "db.internal",
+		"ENV_CONFIG_PORT":     "8080",
+		"SERVICE_ZONE":        "eu",
+		"ENV_CONFIG_PASSWORD": "****",
+		"VAULT_TOKEN":         "****",
+	}
+	if !reflect.DeepEqual(snapshot, want) {
+		t.Errorf("expected %v, got %v", want, snapshot)
+	}
+	if s.Password != "hunter2" {
+		t.Errorf("expected %s, got %s", "hunter2", s.Password)
+	}
+}
+
+func TestNumericEdgeKinds(t *testing.T) {
+	var s struct {
+		Address uintptr
+		Phase   complex128
+		Small   complex64
+	}
+	os.Clearenv()
+	os.Setenv("ENV_CONFIG_ADDRESS", "0xdeadbeef")
+	os.Setenv("ENV_CONFIG_PHASE", "1+2i")
+	os.Setenv("ENV_CONFIG_SMALL", "3i")
+	if err := Process("env_config", &s); err != nil {
+		t.Fatal(err.Error())
+	}
+	if s.Address != 0xdeadbeef {
+		t.Errorf("expected %#x, got %#x", 0xdeadbeef, s.Address)
+	}
+	if s.Phase != 1+2i {
+		t.Errorf("expected %v, got %v", 1+2i, s.Phase)
+	}
+	if s.Small != 3i {
+		t.Errorf("expected %v, got %v", 3i, s.Small)
+	}
+}
+
+func TestUnsupportedKind(t *testing.T) {
+	var s struct {
+		Events chan string
+	}
+	os.Clearenv()
+	os.Setenv("ENV_CONFIG_EVENTS", "x")
+	err := Process("env_config", &s)
+	v, ok := err.(*ParseError)
+	if !ok {
+		t.Fatalf("expected ParseError, got %T %v", err, err)
+	}
+	if experr := "unsupported type chan string"; v.Err.Error() != experr {
+		t.Errorf("expected %s, got %v", experr, v.Err)
+	}
+}
+
+func TestTrim(t *testing.T) {
+	var s struct {
+		Port   int            `trim:"true"`
+		Debug  bool           `trim:"true"`
+		Ports  []int          `trim:"true"`
+		Limits map[string]int `trim:"true"`
+		Name   string
+	}
+	os.Clearenv()
+	os.Setenv("ENV_CONFIG_PORT", "8080\n")
+	os.Setenv("ENV_CONFIG_DEBUG", " true ")
+	os.Setenv("ENV_CONFIG_PORTS", "80, 443 ,\t8080")
+	os.Setenv("ENV_CONFIG_LIMITS", "cpu: 2, memory :512\n")
+	os.Setenv("ENV_CONFIG_NAME", " app ")
+	if err := Process("env_config", &s); err != nil {
+		t.Fatal(err.Error())
+	}
+	if s.Port != 8080 || !s.Debug {
+		t.Errorf("expected %d and %t, got %d and %t", 8080, true, s.Port, s.Debug)
+	}
+	if want := []int{80, 443, 8080}; !reflect.DeepEqual(s.Ports, want) {
+		t.Errorf("expected %v, got %v", want, s.Ports)
+	}
+	if want := map[string]int{"cpu": 2, "memory": 512}; !reflect.DeepEqual(s.Limits, want) {
+		t.Errorf("expected %v, got %v", want, s.Limits)
+	}
+	if s.Name != " app " {
+		t.Errorf("expected %q, got %q", " app ", s.Name)
+	}
+
+	if err := Process("env_config", &s, WithTrimSpace()); err != nil {
+		t.Fatal(err.Error())
+	}
+	if s.Name != "app" {
+		t.Errorf("expected %q, got %q", "app", s.Name)
+	}
+}
+
+func TestTransform(t *testing.T) {
+	var s struct {
+		Cache   string   `transform:"trim,lower,trim_prefix=redis://"`
+		Regions []string `transform:"trim,upper"`
+		Level   string   `transform:"lower" oneof:"debug info"`
+		Port    int      `transform:"trim=[]"`
+	}
+	os.Clearenv()
+	os.Setenv("ENV_CONFIG_CACHE", " REDIS://Cache:6379 ")
+	os.Setenv("ENV_CONFIG_REGIONS", "us-east, eu-west")
+	os.Setenv("ENV_CONFIG_LEVEL", "INFO")
+	os.Setenv("ENV_CONFIG_PORT", "[8080]")
+	if err := Process("env_config", &s); err != nil {
+		t.Fatal(err.Error())
+	}
+	if want := "cache:6379"; s.Cache != want {
+		t.Errorf("expected %q, got %q", want, s.Cache)
+	}
+	if want := []string{"US-EAST", "EU-WEST"}; !reflect.DeepEqual(s.Regions, want) {
+		t.Errorf("expected %q, got %q", want, s.Regions)
+	}
+	if want := "info"; s.Level != want {
+		t.Errorf("expected %q, got %q", want, s.Level)
+	}
+	if s.Port != 8080 {
+		t.Errorf("expected %d, got %d", 8080, s.Port)
+	}
+
+	RegisterTransform("reverse", func(value, _ string) (string, error) {
+		r := []rune(value)
+		for i, j := 0, len(r)-1; i < j; i, j = i+1, j-1 {
+			r[i], r[j] = r[j], r[i]
+		}
+		return string(r), nil
+	})
+	var custom struct {
+		Name string `transform:"reverse,upper"`
+	}
+	os.Setenv("ENV_CONFIG_NAME", "abc")
+	if err := Process("env_config", &custom); err != nil {
+		t.Fatal(err.Error())
+	}
+	if want := "CBA"; custom.Name != want {
+		t.Errorf("expected %q, got %q", want, custom.Name)
+	}
+
+	var unknown struct {
+		Name string `transform:"trim,title"`
+	}
+	err := Process("env_config", &unknown)
+	if experr := `envconfig: unknown transform "title" for Name`; err == nil || err.Error() != experr {
+		t.Errorf("expected %s, got %v", experr, err)
+	}
+}
+
+func TestStripInlineComment(t *testing.T) {
+	tests := []struct {
+		value, want string
+	}{
+		{"value # note", "value"},
+		{"value\t\t# note", "value"},
+		{"value", "value"},
+		{"color#fff", "color#fff"},
+		{"#fff", "#fff"},
+		{`"a # b" # note`, `"a # b"`},
+		{`'a # b'`, `'a # b'`},
+		{"a, b # trailing", "a, b"},
+	}
+	for _, test := range tests {
+		var s struct {
+			Value    string `strip_inline_comment:"true"`
+			Verbatim string
+		}
+		os.Clearenv()
+		os.Setenv("ENV_CONFIG_VALUE", test.value)
+		os.Setenv("ENV_CONFIG_VERBATIM", test.value)
+		if err := Process("env_config", &s); err != nil {
+			t.Fatal(err.Error())
+		}
+		if s.Value != test.want {
+			t.Errorf("expected %q, got %q", test.want, s.Value)
+		}
+		if s.Verbatim != test.value {
+			t.Errorf("expected %q, got %q", test.value, s.Verbatim)
+		}
+	}
+
+	var typed struct {
+		Port  int      `strip_inline_comment:"true"`
+		Hosts []string `strip_inline_comment:"true"`
+		Name  string   `strip_inline_comment:"true" dequote:"true"`
+	}
+	os.Clearenv()
+	os.Setenv("ENV_CONFIG_PORT", "8080 # http")
+	os.Setenv("ENV_CONFIG_HOSTS", "a,b # primaries")
+	os.Setenv("ENV_CONFIG_NAME", `"x # y"  # quoted`)
+	if err := Process("env_config", &typed); err != nil {
+		t.Fatal(err.Error())
+	}
+	if typed.Port != 8080 {
+		t.Errorf("expected %d, got %d", 8080, typed.Port)
+	}
+	if want := []string{"a", "b"}; !reflect.DeepEqual(typed.Hosts, want) {
+		t.Errorf("expected %q, got %q", want, typed.Hosts)
+	}
+	if want := "x # y"; typed.Name != want {
+		t.Errorf("expected %q, got %q", want, typed.Name)
+	}
+}
+
+func TestDequote(t *testing.T) {
+	var s struct {
+		Greeting string   `dequote:"true"`
+		Port     int      `dequote:"true"`
+		Names    []string `dequote:"true"`
+		Partial  string   `dequote:"true"`
+		Kept     string
+	}
+	os.Clearenv()
+	os.Setenv("ENV_CONFIG_GREETING", `'hello'`)
+	os.Setenv("ENV_CONFIG_PORT", `"8080"`)
+	os.Setenv("ENV_CONFIG_NAMES", `"a",'b',c,"d'`)
+	os.Setenv("ENV_CONFIG_PARTIAL", `'hello`)
+	os.Setenv("ENV_CONFIG_KEPT", `"hello"`)
+	if err := Process("env_config", &s); err != nil {
+		t.Fatal(err.Error())
+	}
+	if s.Greeting != "hello" {
+		t.Errorf("expected %s, got %s", "hello", s.Greeting)
+	}
+	if s.Port != 8080 {
+		t.Errorf("expected %d, got %d", 8080, s.Port)
+	}
+	if want := []string{"a", "b", "c", `"d'`}; !reflect.DeepEqual(s.Names, want) {
+		t.Errorf("expected %q, got %q", want, s.Names)
+	}
+	if s.Partial != `'hello` {
+		t.Errorf("expected %s, got %s", `'hello`, s.Partial)
+	}
+	if s.Kept != `"hello"` {
+		t.Errorf("expected %s, got %s", `"hello"`, s.Kept)
+	}
+}
+
+func TestDurationUnit(t *testing.T) {
+	var s struct {
+		Timeout time.Duration `unit:"s" default:"30"`
+		Backoff time.Duration `unit:"ms"`
+	}
+	os.Clearenv()
+	if err := Process("env_config", &s); err != nil {
+		t.Fatal(err.Error())
+	}
+	if want := 30 * time.Second; s.Timeout != want {
+		t.Errorf("expected %s, got %s", want, s.Timeout)
+	}
+
+	os.Setenv("ENV_CONFIG_TIMEOUT", "1m")
+	os.Setenv("ENV_CONFIG_BACKOFF", "2.5")
+	if err := Process("env_config", &s); err != nil {
+		t.Fatal(err.Error())
+	}
+	if want := time.Minute; s.Timeout != want {
+		t.Errorf("expected %s, got %s", want, s.Timeout)
+	}
+	if want := 2500 * time.Microsecond; s.Backoff != want {
+		t.Errorf("expected %s, got %s", want, s.Backoff)
+	}
+}
+
+func TestDurationFractionalSeconds(t *testing.T) {
+	var s struct {
+		Timeout time.Duration `unit:"s"`
+	}
+	tests := []struct {
+		value  string
+		want   time.Duration
+		experr string
+	}{
+		{"1.5", 1500 * time.Millisecond, ""},
+		{"2", 2 * time.Second, ""},
+		{"0.1", 100 * time.Millisecond, ""},
+		{"1e-3", time.Millisecond, ""},
+		{"-0.25", -250 * time.Millisecond, ""},
+		{"fast", 0, `time: invalid duration "fast"`},
+		{"NaN", 0, "duration NaNs out of range"},
+		{"1e12", 0, "duration 1e12s out of range"},
+	}
+	for _, test := range tests {
+		os.Clearenv()
+		os.Setenv("ENV_CONFIG_TIMEOUT", test.value)
+		err := Process("env_config", &s)
+		if test.experr != "" {
+			v, ok := err.(*ParseError)
+			if !ok {
+				t.Fatalf("expected ParseError, got %T %v", err, err)
+			}
+			if v.Err.Error() != test.experr {
+				t.Errorf("expected %s, got %v", test.experr, v.Err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: expected no error, got %v", test.value, err)
+		}
+		if s.Timeout != test.want {
+			t.Errorf("expected %s, got %s", test.want, s.Timeout)
+		}
+	}
+}
+
+func TestDurationBounds(t *testing.T) {
+	var s struct {
+		Skew time.Duration `min:"-5m" max:"5m"`
+	}
+	tests := []struct {
+		value  string
+		want   time.Duration
+		experr string
+	}{
+		{"-2m30s", -150 * time.Second, ""},
+		{"5m", 5 * time.Minute, ""},
+		{"-10m", 0, "duration -10m0s is below the minimum of -5m0s"},
+		{"6m", 0, "duration 6m0s is above the maximum of 5m0s"},
+	}
+	for _, test := range tests {
+		os.Clearenv()
+		os.Setenv("ENV_CONFIG_SKEW", test.value)
+		s.Skew = 0
+		err := Process("env_config", &s)
+		if test.experr == "" {
+			if err != nil {
+				t.Fatal(err.Error())
+			}
+			if s.Skew != test.want {
+				t.Errorf("expected %s, got %s", test.want, s.Skew)
+			}
+			continue
+		}
+		v, ok := err.(*ParseError)
+		if !ok {
+			t.Fatalf("expected ParseError, got %T %v", err, err)
+		}
+		if v.Err.Error() != test.experr {
+			t.Errorf("expected %s, got %v", test.experr, v.Err)
+		}
+	}
+}
+
+func TestOneOf(t *testing.T) {
+	var s struct {
+		Level  string   `oneof:"debug info warn error"`
+		Format string   `oneof:"json text" oneof_ci:"true"`
+		Levels []string `oneof:"debug info" oneof_ci:"true"`
+	}
+	os.Clearenv()
+	os.Setenv("ENV_CONFIG_LEVEL", "info")
+	os.Setenv("ENV_CONFIG_FORMAT", "JSON")
+	os.Setenv("ENV_CONFIG_LEVELS", "Debug,INFO")
+	if err := Process("env_config", &s); err != nil {
+		t.Fatal(err.Error())
+	}
+	if s.Level != "info" {
+		t.Errorf("expected %q, got %q", "info", s.Level)
+	}
+	if s.Format != "json" {
+		t.Errorf("expected canonical %q, got %q", "json", s.Format)
+	}
+	if want := []string{"debug", "info"}; !reflect.DeepEqual(s.Levels, want) {
+		t.Errorf("expected %#v, got %#v", want, s.Levels)
+	}
+}
+
+type levelName string
+
+func TestOneOfTypedString(t *testing.T) {
+	var s struct {
+		Level  levelName   `oneof:"debug info warn error" default:"info"`
+		Levels []levelName `oneof:"debug info"`
+	}
+	os.Clearenv()
+	if err := Process("env_config", &s); err != nil {
+		t.Fatal(err.Error())
+	}
+	if s.Level != levelName("info") {
+		t.Errorf("expected %q, got %q", "info", s.Level)
+	}
+
+	os.Setenv("ENV_CONFIG_LEVEL", "trace")
+	err := Process("env_config", &s)
+	v, ok := err.(*ParseError)
+	if !ok {
+		t.Fatalf("expected ParseError, got %T %v", err, err)
+	}
+	if experr := `value "trace" is not one of debug, info, warn, error`; v.Err.Error() != experr {
+		t.Errorf("expected %s, got %s", experr, v.Err)
+	}
+
+	buf := new(bytes.Buffer)
+	if err := Usagef("env_config", &s, buf, "{{range .}}{{usage_key .}}={{usage_type .}}\n{{end}}"); err != nil {
+		t.Fatal(err.Error())
+	}
+	want := "ENV_CONFIG_LEVEL=one of: debug, info, warn, error\nENV_CONFIG_LEVELS=Comma-separated list of one of: debug, info\n"
+	if got := buf.String(); got != want {
+		t.Errorf("expected %q, got %q", want, got)
+	}
+}
+
+func TestOneOfMismatchedCase(t *testing.T) {
+	var s struct {
+		Level string `oneof:"debug info warn error"`
+	}
+	os.Clearenv()
+	os.Setenv("ENV_CONFIG_LEVEL", "INFO")
+	err := Process("env_config", &s)
+	v, ok := err.(*ParseError)
+	if !ok {
+		t.Fatalf("expected ParseError, got %T %v", err, err)
+	}
+	if experr := `value "INFO" is not one of debug, info, warn, error`; v.Err.Error() != experr {
+		t.Errorf("expected %s, got %s", experr, v.Err)
+	}
+}
+
+func TestEncodedBytes(t *testing.T) {
+	var s struct {
+		HexKey    [4]byte `encoding:"hex"`
+		Base64Key [4]byte `encoding:"base64"`
+		RawKey    [4]byte
+		Token     []byte `encoding:"base64"`
+	}
+	os.Clearenv()
+	os.Setenv("ENV_CONFIG_HEXKEY", "deadbeef")
+	os.Setenv("ENV_CONFIG_BASE64KEY", "3q2+7w==")
+	os.Setenv("ENV_CONFIG_RAWKEY", "abcd")
+	os.Setenv("ENV_CONFIG_TOKEN", "aGVsbG8=")
+	if err := Process("env_config", &s); err != nil {
+		t.Fatal(err.Error())
+	}
+
+	want := [4]byte{0xde, 0xad, 0xbe, 0xef}
+	if s.HexKey != want {
+		t.Errorf("expected %x, got %x", want, s.HexKey)
+	}
+	if s.Base64Key != want {
+		t.Errorf("expected %x, got %x", want, s.Base64Key)
+	}
+	if s.RawKey != [4]byte{'a', 'b', 'c', 'd'} {
+		t.Errorf("expected %q, got %q", "abcd", s.RawKey)
+	}
+	if string(s.Token) != "hello" {
+		t.Errorf("expected %q, got %q", "hello", s.Token)
+	}
+}
+
+func TestBase32Bytes(t *testing.T) {
+	var s struct {
+		Secret []byte  `encoding:"base32"`
+		Key    [5]byte `encoding:"base32"`
+	}
+	tests := []struct {
+		value  string
+		want   string
+		experr bool
+	}{
+		{"NBSWY3DP", "hello", false},
+		{"MZXW6===", "foo", false},
+		{"MZXW6", "foo", false},
+		{"MZXW6!!!", "", true},
+	}
+	for _, test := range tests {
+		os.Clearenv()
+		os.Setenv("ENV_CONFIG_SECRET", test.value)
+		err := Process("env_config", &s)
+		if test.experr {
+			if _, ok := err.(*ParseError); !ok {
+				t.Errorf("%s: expected ParseError, got %T %v", test.value, err, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: expected no error, got %v", test.value, err)
+		}
+		if string(s.Secret) != test.want {
+			t.Errorf("expected %q, got %q", test.want, s.Secret)
+		}
+	}
+
+	os.Clearenv()
+	os.Setenv("ENV_CONFIG_KEY", "NBSWY3DP")
+	if err := Process("env_config", &s); err != nil {
+		t.Fatal(err.Error())
+	}
+	if s.Key != [5]byte{'h', 'e', 'l', 'l', 'o'} {
+		t.Errorf("expected %q, got %q", "hello", s.Key)
+	}
+}
+
+func TestEncodedBytesLength(t *testing.T) {
+	var s struct {
+		Key [4]byte `encoding:"hex"`
+	}
+	tests := []struct {
+		value, want string
+	}{
+		{"deadbe", "expected 4 bytes, got 3"},
+		{"deadbeef00", "expected 4 bytes, got 5"},
+	}
+	for _, test := range tests {
+		os.Clearenv()
+		os.Setenv("ENV_CONFIG_KEY", test.value)
+		err := Process("env_config", &s)
+		v, ok := err.(*ParseError)
+		if !ok {
+			t.Fatalf("expected ParseError, got %T %v", err, err)
+		}
+		if v.Err.Error() != test.want {
+			t.Errorf("expected %q, got %q", test.want, v.Err)
+		}
+	}
+}
+
+func TestLocation(t *testing.T) {
+	var s struct {
+		TZ       *time.Location `envconfig:"TIMEZONE"`
+		Zone     time.Location
+		Unset    *time.Location
+		Explicit *time.Location
+	}
+	os.Clearenv()
+	os.Setenv("ENV_CONFIG_TIMEZONE", "America/New_York")
+	os.Setenv("ENV_CONFIG_ZONE", "Europe/Berlin")
+	os.Setenv("ENV_CONFIG_EXPLICIT", "")
+	if err := Process("env_config", &s); err != nil {
+		t.Fatal(err.Error())
+	}
+
+	if s.TZ == nil || s.TZ.String() != "America/New_York" {
+		t.Errorf("expected %s, got %v", "America/New_York", s.TZ)
+	}
+	if s.Zone.String() != "Europe/Berlin" {
+		t.Errorf("expected %s, got %v", "Europe/Berlin", s.Zone.String())
+	}
+	if s.Unset != nil {
+		t.Errorf("expected <nil>, got %v", s.Unset)
+	}
+	if s.Explicit != nil {
+		t.Errorf("expected <nil>, got %v", s.Explicit)
+	}
+}
+
+func TestLocationError(t *testing.T) {
+	var s struct {
+		TZ *time.Location
+	}
+	os.Clearenv()
+	os.Setenv("ENV_CONFIG_TZ", "Mars/Olympus_Mons")
+	err := Process("env_config", &s)
+	v, ok := err.(*ParseError)
+	if !ok {
+		t.Fatalf("expected ParseError, got %T %v", err, err)
+	}
+	if v.FieldName != "TZ" {
+		t.Errorf("expected %s, got %v", "TZ", v.FieldName)
+	}
+}
+
+func TestIPFields(t *testing.T) {
+	var s struct {
+		Bind    net.IP
+		Gateway *net.IP
+		Subnet  net.IPNet
+		Allowed *net.IPNet
+		Blocked []*net.IPNet
+		Unset   *net.IPNet
+	}
+	os.Clearenv()
+	os.Setenv("ENV_CONFIG_BIND", "10.0.0.1")
+	os.Setenv("ENV_CONFIG_GATEWAY", "::1")
+	os.Setenv("ENV_CONFIG_SUBNET", "10.0.0.0/8")
+	os.Setenv("ENV_CONFIG_ALLOWED", "192.168.1.7/24")
+	os.Setenv("ENV_CONFIG_BLOCKED", "172.16.0.0/12,fd00::/8")
+	if err := Process("env_config", &s); err != nil {
+		t.Fatal(err.Error())
+	}
+	if !s.Bind.Equal(net.ParseIP("10.0.0.1")) {
+		t.Errorf("expected %s, got %s", "10.0.0.1", s.Bind)
+	}
+	if s.Gateway == nil || !s.Gateway.Equal(net.IPv6loopback) {
+		t.Errorf("expected %s, got %v", "::1", s.Gateway)
+	}
+	if want := "10.0.0.0/8"; s.Subnet.String() != want {
+		t.Errorf("expected %s, got %s", want, s.Subnet.String())
+	}
+	if want := "192.168.1.0/24"; s.Allowed == nil || s.Allowed.String() != want {
+		t.Errorf("expected %s, got %v", want, s.Allowed)
+	}
+	if len(s.Blocked) != 2 || s.Blocked[1].String() != "fd00::/8" {
+		t.Errorf("expected %s, got %v", "fd00::/8", s.Blocked)
+	}
+	if s.Unset != nil {
+		t.Errorf("expected <nil>, got %v", s.Unset)
+	}
+	for _, test := range []struct {
+		typ  reflect.Type
+		want string
+	}{
+		{reflect.TypeOf(s.Bind), "IP Address"},
+		{reflect.TypeOf(s.Allowed), "CIDR"},
+	} {
+		if got := toTypeDescription(test.typ, ""); got != test.want {
+			t.Errorf("expected %q, got %q", test.want, got)
+		}
+	}
+
+	tests := []struct {
+		key, value, experr string
+	}{
+		{"ENV_CONFIG_BIND", "not-an-ip", "invalid IP address: not-an-ip"},
+		{"ENV_CONFIG_SUBNET", "10.0.0.0", "invalid CIDR address: 10.0.0.0"},
+	}
+	for _, test := range tests {
+		os.Clearenv()
+		os.Setenv(test.key, test.value)
+		err := Process("env_config", &s)
+		v, ok := err.(*ParseError)
+		if !ok {
+			t.Fatalf("expected ParseError, got %T %v", err, err)
+		}
+		if v.KeyName != test.key || v.Err.Error() != test.experr {
+			t.Errorf("expected %s: %s, got %s: %v", test.key, test.experr, v.KeyName, v.Err)
+		}
+	}
+}
+
+func TestTimeFormat(t *testing.T) {
+	var s struct {
+		Started   time.Time
+		StartDate time.Time   `time_format:"2006-01-02"`
+		EndDate   *time.Time  `time_format:"2006-01-02"`
+		Holidays  []time.Time `time_format:"Jan 2"`
+		Unset     *time.Time  `time_format:"2006-01-02"`
+	}
+	os.Clearenv()
+	os.Setenv("ENV_CONFIG_STARTED", "2016-08-16T18:57:05Z")
+	os.Setenv("ENV_CONFIG_STARTDATE", "2024-03-01")
+	os.Setenv("ENV_CONFIG_ENDDATE", "2024-12-31")
+	os.Setenv("ENV_CONFIG_HOLIDAYS", "Jan 1,Dec 25")
+	if err := Process("env_config", &s); err != nil {
+		t.Fatal(err.Error())
+	}
+	if want := time.Date(2016, 8, 16, 18, 57, 5, 0, time.UTC); !s.Started.Equal(want) {
+		t.Errorf("expected %s, got %s", want, s.Started)
+	}
+	if want := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC); !s.StartDate.Equal(want) {
+		t.Errorf("expected %s, got %s", want, s.StartDate)
+	}
+	if want := time.Date(2024, 12, 31, 0, 0, 0, 0, time.UTC); s.EndDate == nil || !s.EndDate.Equal(want) {
+		t.Errorf("expected %s, got %v", want, s.EndDate)
+	}
+	if len(s.Holidays) != 2 || s.Holidays[1].Month() != time.December || s.Holidays[1].Day() != 25 {
+		t.Errorf("expected %s, got %v", "Dec 25", s.Holidays)
+	}
+	if s.Unset != nil {
+		t.Errorf("expected <nil>, got %v", s.Unset)
+	}
+
+	buf := new(bytes.Buffer)
+	var doc struct {
+		StartDate time.Time `time_format:"2006-01-02"`
+		Started   time.Time
+	}
+	if err := Usagef("env_config", &doc, buf, "{{range .}}{{usage_key .}}={{usage_type .}}\n{{end}}"); err != nil {
+		t.Fatal(err.Error())
+	}
+	if want := "ENV_CONFIG_STARTDATE=Time (2006-01-02)\nENV_CONFIG_STARTED=Time\n"; buf.String() != want {
+		t.Errorf("expected %q, got %q", want, buf.String())
+	}
+
+	os.Setenv("ENV_CONFIG_STARTDATE", "2024-03-01T00:00:00Z")
+	err := Process("env_config", &s)
+	v, ok := err.(*ParseError)
+	if !ok {
+		t.Fatalf("expected ParseError, got %T %v", err, err)
+	}
+	if v.FieldName != "StartDate" {
+		t.Errorf("expected %s, got %v", "StartDate", v.FieldName)
+	}
+}
+
+func TestHardwareAddr(t *testing.T) {
+	var s struct {
+		MAC     net.HardwareAddr `envconfig:"MAC"`
+		Backup  *net.HardwareAddr
+		Peers   []net.HardwareAddr
+		Missing net.HardwareAddr
+	}
+	os.Clearenv()
+	os.Setenv("ENV_CONFIG_MAC", "01:23:45:67:89:ab")
+	os.Setenv("ENV_CONFIG_BACKUP", "01-23-45-67-89-ac")
+	os.Setenv("ENV_CONFIG_PEERS", "01:23:45:67:89:ad,0123.4567.89ae")
+	if err := Process("env_config", &s); err != nil {
+		t.Fatal(err.Error())
+	}
+	if want := "01:23:45:67:89:ab"; s.MAC.String() != want {
+		t.Errorf("expected %s, got %s", want, s.MAC)
+	}
+	if want := "01:23:45:67:89:ac"; s.Backup == nil || s.Backup.String() != want {
+		t.Errorf("expected %s, got %v", want, s.Backup)
+	}
+	if len(s.Peers) != 2 || s.Peers[1].String() != "01:23:45:67:89:ae" {
+		t.Errorf("expected %s, got %v", "01:23:45:67:89:ae", s.Peers)
+	}
+	if s.Missing != nil {
+		t.Errorf("expected <nil>, got %v", s.Missing)
+	}
+	if got := toTypeDescription(reflect.TypeOf(s.MAC), ""); got != "MAC Address" {
+		t.Errorf("expected %q, got %q", "MAC Address", got)
+	}
+
+	os.Setenv("ENV_CONFIG_MAC", "01:23:45:67:89")
+	err := Process("env_config", &s)
+	v, ok := err.(*ParseError)
+	if !ok {
+		t.Fatalf("expected ParseError, got %T %v", err, err)
+	}
+	if v.FieldName != "MAC" {
+		t.Errorf("expected %s, got %v", "MAC", v.FieldName)
 	}
 }
 
-func TestRequiredVar(t *testing.T) {
-	var s Specification
+func TestAddressSlices(t *testing.T) {
+	var s struct {
+		Resolvers []net.IP
+		Mirrors   []*url.URL
+	}
 	os.Clearenv()
-	os.Setenv("ENV_CONFIG_REQUIREDVAR", "foobar")
+	os.Setenv("ENV_CONFIG_RESOLVERS", "1.1.1.1,2.2.2.2")
+	os.Setenv("ENV_CONFIG_MIRRORS", "https://a.example.com/pub,http://b.example.com")
 	if err := Process("env_config", &s); err != nil {
-		t.Error(err.Error())
+		t.Fatal(err.Error())
+	}
+	if want := []net.IP{net.ParseIP("1.1.1.1"), net.ParseIP("2.2.2.2")}; !reflect.DeepEqual(s.Resolvers, want) {
+		t.Errorf("expected %v, got %v", want, s.Resolvers)
+	}
+	if len(s.Mirrors) != 2 || s.Mirrors[0].Host != "a.example.com" || s.Mirrors[1].String() != "http://b.example.com" {
+		t.Errorf("expected %s and %s, got %v", "https://a.example.com/pub", "http://b.example.com", s.Mirrors)
 	}
 
-	if s.RequiredVar != "foobar" {
-		t.Errorf("expected %s, got %s", "foobar", s.RequiredVar)
+	tests := []struct {
+		key, value, experr string
+	}{
+		{"ENV_CONFIG_RESOLVERS", "1.1.1.1,2.2.2", `element 1: invalid IP address: 2.2.2`},
+		{"ENV_CONFIG_MIRRORS", "https://a.example.com,:bad", `element 1: parse ":bad": missing protocol scheme`},
+	}
+	for _, test := range tests {
+		os.Clearenv()
+		os.Setenv(test.key, test.value)
+		err := Process("env_config", &s)
+		v, ok := err.(*ParseError)
+		if !ok {
+			t.Fatalf("expected ParseError, got %T %v", err, err)
+		}
+		if v.Err.Error() != test.experr {
+			t.Errorf("expected %s, got %v", test.experr, v.Err)
+		}
 	}
-}
 
-func TestRequiredMissing(t *testing.T) {
-	var s Specification
+	// the element error is wrapped, not flattened
+	var small struct {
+		Levels []int8
+	}
 	os.Clearenv()
-
-	err := Process("env_config", &s)
-	if err == nil {
-		t.Error("no failure when missing required variable")
+	os.Setenv("ENV_CONFIG_LEVELS", "1,300")
+	if err := Process("env_config", &small); !errors.Is(err, strconv.ErrRange) {
+		t.Errorf("expected %v, got %v", strconv.ErrRange, err)
 	}
 }
 
-func TestBlankDefaultVar(t *testing.T) {
-	var s Specification
+type server struct {
+	Host string
+	Port int `default:"80"`
+}
+
+func TestJSONStructSlice(t *testing.T) {
+	var s struct {
+		Servers []server `json:"true"`
+	}
 	os.Clearenv()
-	os.Setenv("ENV_CONFIG_REQUIREDVAR", "requiredvalue")
+	os.Setenv("ENV_CONFIG_SERVERS", `[{"Host":"a","Port":1},{"Host":"b","Port":2}]`)
+	os.Setenv("ENV_CONFIG_SERVERS_1_HOST", "c")
 	if err := Process("env_config", &s); err != nil {
-		t.Error(err.Error())
+		t.Fatal(err.Error())
 	}
-
-	if s.DefaultVar != "foobar" {
-		t.Errorf("expected %s, got %s", "foobar", s.DefaultVar)
+	want := []server{{Host: "a", Port: 1}, {Host: "c", Port: 2}}
+	if !reflect.DeepEqual(s.Servers, want) {
+		t.Errorf("expected %v, got %v", want, s.Servers)
 	}
+}
 
-	if *s.SomePointerWithDefault != "foo2baz" {
-		t.Errorf("expected %s, got %s", "foo2baz", *s.SomePointerWithDefault)
-	}
+type node struct {
+	Name string
+	Next *node
 }
 
-func TestNonBlankDefaultVar(t *testing.T) {
-	var s Specification
+type tree struct {
+	Name     string
+	Children []tree
+}
+
+func TestRecursiveType(t *testing.T) {
+	var s struct {
+		Head node
+	}
 	os.Clearenv()
-	os.Setenv("ENV_CONFIG_DEFAULTVAR", "nondefaultval")
-	os.Setenv("ENV_CONFIG_REQUIREDVAR", "requiredvalue")
-	if err := Process("env_config", &s); err != nil {
-		t.Error(err.Error())
+	err := Process("env_config", &s)
+	if experr := "envconfig: type envconfig.node is recursive through field Next"; err == nil || err.Error() != experr {
+		t.Errorf("expected %s, got %v", experr, err)
 	}
 
-	if s.DefaultVar != "nondefaultval" {
-		t.Errorf("expected %s, got %s", "nondefaultval", s.DefaultVar)
+	// indexed elements are only gathered for the indices set, so a recursive
+	// slice is fine to process but cannot be listed by KnownKeys
+	var r tree
+	os.Setenv("ENV_CONFIG_CHILDREN_0_NAME", "leaf")
+	if err := Process("env_config", &r); err != nil {
+		t.Fatal(err.Error())
+	}
+	if len(r.Children) != 1 || r.Children[0].Name != "leaf" {
+		t.Errorf("expected %v, got %v", []tree{{Name: "leaf"}}, r.Children)
+	}
+	_, err = KnownKeys("env_config", &r)
+	if experr := "envconfig: type envconfig.tree is recursive through field Children"; err == nil || err.Error() != experr {
+		t.Errorf("expected %s, got %v", experr, err)
 	}
 }
 
-func TestExplicitBlankDefaultVar(t *testing.T) {
-	var s Specification
+func TestIndexedMap(t *testing.T) {
+	var s struct {
+		Names   map[int]string `indexed:"true"`
+		Servers map[uint16]server
+	}
 	os.Clearenv()
-	os.Setenv("ENV_CONFIG_DEFAULTVAR", "")
-	os.Setenv("ENV_CONFIG_REQUIREDVAR", "")
-
+	os.Setenv("ENV_CONFIG_NAMES", "1:one")
+	os.Setenv("ENV_CONFIG_NAMES_0", "zero")
+	os.Setenv("ENV_CONFIG_NAMES_10", "ten")
+	os.Setenv("ENV_CONFIG_SERVERS_3_HOST", "c")
+	os.Setenv("ENV_CONFIG_SERVERS_7_HOST", "g")
+	os.Setenv("ENV_CONFIG_SERVERS_7_PORT", "8080")
 	if err := Process("env_config", &s); err != nil {
-		t.Error(err.Error())
+		t.Fatal(err.Error())
+	}
+	if want := map[int]string{0: "zero", 1: "one", 10: "ten"}; !reflect.DeepEqual(s.Names, want) {
+		t.Errorf("expected %v, got %v", want, s.Names)
+	}
+	want := map[uint16]server{3: {Host: "c", Port: 80}, 7: {Host: "g", Port: 8080}}
+	if !reflect.DeepEqual(s.Servers, want) {
+		t.Errorf("expected %v, got %v", want, s.Servers)
+	}
+	if err := CheckDisallowed("env_config", &s); err != nil {
+		t.Errorf("expected no error, got %v", err)
+	}
+	values, err := EffectiveConfig("env_config", &s)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if values["ENV_CONFIG_SERVERS_7_PORT"] != "8080" || values["ENV_CONFIG_NAMES_10"] != "ten" {
+		t.Errorf("expected indexed entries, got %v", values)
 	}
 
-	if s.DefaultVar != "" {
-		t.Errorf("expected %s, got %s", "\"\"", s.DefaultVar)
+	os.Setenv("ENV_CONFIG_SERVERS_X_HOST", "x")
+	err = Process("env_config", &s)
+	if experr := "envconfig: ENV_CONFIG_SERVERS_X_HOST is not indexed by an integer"; err == nil || err.Error() != experr {
+		t.Errorf("expected %s, got %v", experr, err)
 	}
 }
 
-func TestAlternateNameDefaultVar(t *testing.T) {
-	var s Specification
+func TestIndexedMapSibling(t *testing.T) {
+	var s struct {
+		Hosts     map[int]server
+		HostsFile string `split_words:"true"`
+	}
 	os.Clearenv()
-	os.Setenv("BROKER", "betterbroker")
-	os.Setenv("ENV_CONFIG_REQUIREDVAR", "foo")
+	os.Setenv("ENV_CONFIG_HOSTS_1_HOST", "a")
+	os.Setenv("ENV_CONFIG_HOSTS_FILE", "/etc/hosts")
 	if err := Process("env_config", &s); err != nil {
-		t.Error(err.Error())
+		t.Fatal(err.Error())
 	}
-
-	if s.NoPrefixDefault != "betterbroker" {
-		t.Errorf("expected %q, got %q", "betterbroker", s.NoPrefixDefault)
+	if want := map[int]server{1: {Host: "a", Port: 80}}; !reflect.DeepEqual(s.Hosts, want) {
+		t.Errorf("expected %v, got %v", want, s.Hosts)
+	}
+	if s.HostsFile != "/etc/hosts" {
+		t.Errorf("expected %s, got %s", "/etc/hosts", s.HostsFile)
+	}
+	if err := CheckDisallowed("env_config", &s); err != nil {
+		t.Errorf("expected no error, got %v", err)
 	}
+	if _, err := Inspect("env_config", &s); err != nil {
+		t.Errorf("expected no error, got %v", err)
+	}
+}
 
+func TestIndexedStructSlice(t *testing.T) {
+	var s struct {
+		Servers []*server
+	}
 	os.Clearenv()
-	os.Setenv("ENV_CONFIG_REQUIREDVAR", "foo")
+	os.Setenv("ENV_CONFIG_SERVERS_0_HOST", "a")
+	os.Setenv("ENV_CONFIG_SERVERS_2_HOST", "c")
+	os.Setenv("ENV_CONFIG_SERVERS_2_PORT", "8080")
 	if err := Process("env_config", &s); err != nil {
-		t.Error(err.Error())
+		t.Fatal(err.Error())
 	}
-
-	if s.NoPrefixDefault != "127.0.0.1" {
-		t.Errorf("expected %q, got %q", "127.0.0.1", s.NoPrefixDefault)
+	if len(s.Servers) != 3 {
+		t.Fatalf("expected %d, got %d", 3, len(s.Servers))
+	}
+	if *s.Servers[0] != (server{Host: "a", Port: 80}) {
+		t.Errorf("expected %v, got %v", server{Host: "a", Port: 80}, *s.Servers[0])
+	}
+	if *s.Servers[1] != (server{Port: 80}) {
+		t.Errorf("expected %v, got %v", server{Port: 80}, *s.Servers[1])
+	}
+	if *s.Servers[2] != (server{Host: "c", Port: 8080}) {
+		t.Errorf("expected %v, got %v", server{Host: "c", Port: 8080}, *s.Servers[2])
+	}
+	if err := CheckDisallowed("env_config", &s); err != nil {
+		t.Errorf("expected no error, got %v", err)
 	}
 }
 
-func TestRequiredDefault(t *testing.T) {
-	var s Specification
+func TestJSONStructSliceError(t *testing.T) {
+	var s struct {
+		Servers []server `json:"true"`
+	}
 	os.Clearenv()
-	os.Setenv("ENV_CONFIG_REQUIREDVAR", "foo")
-	if err := Process("env_config", &s); err != nil {
-		t.Error(err.Error())
+	os.Setenv("ENV_CONFIG_SERVERS", `[{"Host":`)
+	err := Process("env_config", &s)
+	v, ok := err.(*ParseError)
+	if !ok {
+		t.Fatalf("expected ParseError, got %T %v", err, err)
 	}
-
-	if s.RequiredDefault != "foo2bar" {
-		t.Errorf("expected %q, got %q", "foo2bar", s.RequiredDefault)
+	if v.KeyName != "ENV_CONFIG_SERVERS" {
+		t.Errorf("expected %s, got %v", "ENV_CONFIG_SERVERS", v.KeyName)
 	}
 }
 
-func TestPointerFieldBlank(t *testing.T) {
-	var s Specification
+func TestJSONSliceOfMaps(t *testing.T) {
+	var s struct {
+		Rules []map[string]string `json:"true"`
+	}
 	os.Clearenv()
-	os.Setenv("ENV_CONFIG_REQUIREDVAR", "foo")
+	os.Setenv("ENV_CONFIG_RULES", `[{"a":"1"},{"b":"2","c":"3"}]`)
 	if err := Process("env_config", &s); err != nil {
-		t.Error(err.Error())
+		t.Fatal(err.Error())
+	}
+	want := []map[string]string{{"a": "1"}, {"b": "2", "c": "3"}}
+	if !reflect.DeepEqual(s.Rules, want) {
+		t.Errorf("expected %v, got %v", want, s.Rules)
 	}
 
-	if s.SomePointer != nil {
-		t.Errorf("expected <nil>, got %q", *s.SomePointer)
+	os.Setenv("ENV_CONFIG_RULES", `[{"a":1}]`)
+	err := Process("env_config", &s)
+	if _, ok := err.(*ParseError); !ok {
+		t.Errorf("expected ParseError, got %T %v", err, err)
 	}
 }
 
-func TestEmptyMapFieldOverride(t *testing.T) {
-	var s Specification
+func TestJSONFormat(t *testing.T) {
+	var s struct {
+		Limits  map[string]int `envconfig:"LIMITS,json"`
+		Server  server         `format:"json"`
+		Payload json.RawMessage
+	}
 	os.Clearenv()
-	os.Setenv("ENV_CONFIG_REQUIREDVAR", "foo")
-	os.Setenv("ENV_CONFIG_MAPFIELD", "")
+	os.Setenv("LIMITS", `{"cpu":2,"memory":512}`)
+	os.Setenv("ENV_CONFIG_SERVER", `{"Host":"db","Port":5432}`)
+	os.Setenv("ENV_CONFIG_PAYLOAD", `{"retries": [1, 2]}`)
 	if err := Process("env_config", &s); err != nil {
-		t.Error(err.Error())
+		t.Fatal(err.Error())
 	}
-
-	if s.MapField == nil {
-		t.Error("expected empty map, got <nil>")
+	if want := map[string]int{"cpu": 2, "memory": 512}; !reflect.DeepEqual(s.Limits, want) {
+		t.Errorf("expected %v, got %v", want, s.Limits)
+	}
+	if s.Server.Host != "db" || s.Server.Port != 5432 {
+		t.Errorf("expected %s and %d, got %s and %d", "db", 5432, s.Server.Host, s.Server.Port)
+	}
+	if want := `{"retries": [1, 2]}`; string(s.Payload) != want {
+		t.Errorf("expected %s, got %s", want, s.Payload)
 	}
 
-	if len(s.MapField) != 0 {
-		t.Errorf("expected empty map, got map of size %d", len(s.MapField))
+	os.Setenv("ENV_CONFIG_PAYLOAD", `{"retries": [1, 2}`)
+	err := Process("env_config", &s)
+	v, ok := err.(*ParseError)
+	if !ok {
+		t.Fatalf("expected ParseError, got %T %v", err, err)
+	}
+	if _, ok := v.Err.(*json.SyntaxError); !ok {
+		t.Errorf("expected json.SyntaxError, got %T %v", v.Err, v.Err)
 	}
 }
 
@@ -702,6 +3269,39 @@ func TestNestedStructVarName(t *testing.T) {
 	}
 }
 
+func TestTextUnmarshalerDefault(t *testing.T) {
+	var s struct {
+		Level    upperText   `default:"info"`
+		LevelPtr *upperText  `default:"warn"`
+		Levels   []upperText `default:"a,b"`
+	}
+	os.Clearenv()
+	if err := Process("env_config", &s); err != nil {
+		t.Fatal(err.Error())
+	}
+	if s.Level != "INFO" {
+		t.Errorf("expected %s, got %s", "INFO", s.Level)
+	}
+	if s.LevelPtr == nil || *s.LevelPtr != "WARN" {
+		t.Errorf("expected %s, got %v", "WARN", s.LevelPtr)
+	}
+	if want := []upperText{"A", "B"}; !reflect.DeepEqual(s.Levels, want) {
+		t.Errorf("expected %v, got %v", want, s.Levels)
+	}
+
+	var bad struct {
+		Levels []upperText `default:"x,"`
+	}
+	err := Process("env_config", &bad)
+	v, ok := err.(*ParseError)
+	if !ok {
+		t.Fatalf("expected ParseError, got %T %v", err, err)
+	}
+	if v.FieldName != "Levels" || v.Err.Error() != "element 1: empty text" {
+		t.Errorf("expected %s: %s, got %s: %v", "Levels", "element 1: empty text", v.FieldName, v.Err)
+	}
+}
+
 func TestTextUnmarshalerError(t *testing.T) {
 	var s Specification
 	os.Clearenv()
@@ -792,6 +3392,108 @@ func TestCheckDisallowedIgnored(t *testing.T) {
 	}
 }
 
+func TestProcessStrict(t *testing.T) {
+	var s struct {
+		Host string
+		Port int
+	}
+	os.Clearenv()
+	os.Setenv("ENV_CONFIG_HOST", "localhost")
+	os.Setenv("UNRELATED_ENV_VAR", "true")
+	if err := ProcessStrict("env_config", &s); err != nil {
+		t.Errorf("expected no error, got %s", err)
+	}
+
+	os.Setenv("ENV_CONFIG_PROT", "8080")
+	err := ProcessStrict("env_config", &s)
+	if experr := "unknown environment variable ENV_CONFIG_PROT"; err == nil || err.Error() != experr {
+		t.Errorf("expected %s, got %v", experr, err)
+	}
+	if s.Host != "localhost" {
+		t.Errorf("expected %s, got %s", "localhost", s.Host)
+	}
+
+	os.Setenv("ENV_CONFIG_PORT", "eighty")
+	err = ProcessStrict("env_config", &s)
+	experr := `2 errors: envconfig.Process: assigning ENV_CONFIG_PORT to Port: converting 'eighty' to type int. details: strconv.ParseInt: parsing "eighty": invalid syntax; ` +
+		"unknown environment variable ENV_CONFIG_PROT"
+	if err == nil || err.Error() != experr {
+		t.Errorf("expected %s, got %v", experr, err)
+	}
+}
+
+func TestCheckDisallowedWarn(t *testing.T) {
+	var s Specification
+	os.Clearenv()
+	os.Setenv("ENV_CONFIG_DEBUG", "true")
+	os.Setenv("ENV_CONFIG_ZEBUG", "false")
+	os.Setenv("UNRELATED_ENV_VAR", "true")
+	buf := new(bytes.Buffer)
+	unknown, err := CheckDisallowedWarn("env_config", &s, WithLogger(log.New(buf, "", 0)))
+	if err != nil {
+		t.Errorf("expected no error, got %s", err)
+	}
+	if want := []string{"ENV_CONFIG_ZEBUG"}; !reflect.DeepEqual(unknown, want) {
+		t.Errorf("expected %q, got %q", want, unknown)
+	}
+	if want := "envconfig: unknown environment variable ENV_CONFIG_ZEBUG\n"; buf.String() != want {
+		t.Errorf("expected %q, got %q", want, buf.String())
+	}
+}
+
+func TestRemainder(t *testing.T) {
+	var s struct {
+		Debug bool
+		Host  string            `envconfig:"SERVICE_HOST"`
+		Extra map[string]string `envconfig:",remainder"`
+	}
+	os.Clearenv()
+	os.Setenv("ENV_CONFIG_DEBUG", "true")
+	os.Setenv("ENV_CONFIG_SERVICE_HOST", "127.0.0.1")
+	os.Setenv("ENV_CONFIG_PLUGIN_NAME", "cache")
+	os.Setenv("ENV_CONFIG_PLUGIN_SIZE", "10")
+	os.Setenv("UNRELATED_ENV_VAR", "true")
+	if err := Process("env_config", &s); err != nil {
+		t.Fatal(err.Error())
+	}
+
+	want := map[string]string{"PLUGIN_NAME": "cache", "PLUGIN_SIZE": "10"}
+	if !reflect.DeepEqual(s.Extra, want) {
+		t.Errorf("expected %#v, got %#v", want, s.Extra)
+	}
+	if err := CheckDisallowed("env_config", &s); err != nil {
+		t.Errorf("expected no error, got %s", err)
+	}
+}
+
+func TestRemainderParseError(t *testing.T) {
+	var s struct {
+		Extra map[string]int `envconfig:",remainder"`
+	}
+	os.Clearenv()
+	os.Setenv("ENV_CONFIG_SIZE", "big")
+	err := Process("env_config", &s)
+	v, ok := err.(*ParseError)
+	if !ok {
+		t.Fatalf("expected ParseError, got %T %v", err, err)
+	}
+	if v.KeyName != "ENV_CONFIG_SIZE" {
+		t.Errorf("expected %s, got %v", "ENV_CONFIG_SIZE", v.KeyName)
+	}
+}
+
+func TestMultipleRemainders(t *testing.T) {
+	var s struct {
+		Extra map[string]string `envconfig:",remainder"`
+		More  map[string]string `envconfig:",remainder"`
+	}
+	os.Clearenv()
+	err := Process("env_config", &s)
+	if experr := "envconfig: multiple remainder fields: Extra and More"; err == nil || err.Error() != experr {
+		t.Errorf("expected %s, got %v", experr, err)
+	}
+}
+
 func TestErrorMessageForRequiredAltVar(t *testing.T) {
 	var s struct {
 		Foo string `envconfig:"BAR" required:"true"`
@@ -838,6 +3540,88 @@ func (ss *setterStruct) Set(value string) error {
 	return nil
 }
 
+// upperText is a TextUnmarshaler that upper-cases its input.
+type upperText string
+
+func (u *upperText) UnmarshalText(text []byte) error {
+	if len(text) == 0 {
+		return errors.New("empty text")
+	}
+	*u = upperText(strings.ToUpper(string(text)))
+	return nil
+}
+
+type stringSet map[string]bool
+
+func (s *stringSet) AppendElement(value string) error {
+	if value == "" {
+		return errors.New("empty element")
+	}
+	if *s == nil {
+		*s = make(stringSet)
+	}
+	(*s)[value] = true
+	return nil
+}
+
+func TestElementDecoder(t *testing.T) {
+	var s struct {
+		Tags    stringSet
+		Roles   *stringSet `sep:"|"`
+		Missing stringSet
+	}
+	os.Clearenv()
+	os.Setenv("ENV_CONFIG_TAGS", "a,b,a")
+	os.Setenv("ENV_CONFIG_ROLES", "admin|ops")
+	if err := Process("env_config", &s); err != nil {
+		t.Fatal(err.Error())
+	}
+	if want := (stringSet{"a": true, "b": true}); !reflect.DeepEqual(s.Tags, want) {
+		t.Errorf("expected %v, got %v", want, s.Tags)
+	}
+	if want := (stringSet{"admin": true, "ops": true}); s.Roles == nil || !reflect.DeepEqual(*s.Roles, want) {
+		t.Errorf("expected %v, got %v", want, s.Roles)
+	}
+	if s.Missing != nil {
+		t.Errorf("expected nil, got %v", s.Missing)
+	}
+
+	// processing again replaces the elements rather than adding to them
+	os.Setenv("ENV_CONFIG_TAGS", "c")
+	if err := Process("env_config", &s); err != nil {
+		t.Fatal(err.Error())
+	}
+	if want := (stringSet{"c": true}); !reflect.DeepEqual(s.Tags, want) {
+		t.Errorf("expected %v, got %v", want, s.Tags)
+	}
+
+	os.Setenv("ENV_CONFIG_TAGS", "a,,b")
+	err := Process("env_config", &s)
+	v, ok := err.(*ParseError)
+	if !ok {
+		t.Fatalf("expected ParseError, got %v", err)
+	}
+	if v.FieldName != "Tags" {
+		t.Errorf("expected %s, got %v", "Tags", v.FieldName)
+	}
+}
+
+func TestElementDecoderUsage(t *testing.T) {
+	var s struct {
+		Tags  stringSet
+		Roles *stringSet `sep:"|"`
+	}
+	infos, err := gatherInfo("env_config", &s)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	for i, want := range []string{"Comma-separated stringSet", "Pipe-separated stringSet"} {
+		if got := toTypeDescription(infos[i].Field.Type(), infos[i].Tags); got != want {
+			t.Errorf("expected %q, got %q", want, got)
+		}
+	}
+}
+
 func BenchmarkGatherInfo(b *testing.B) {
 	os.Clearenv()
 	os.Setenv("ENV_CONFIG_DEBUG", "true")
@@ -0,0 +1,104 @@
+// Copyright (c) 2016 Kelsey Hightower and others. All rights reserved.
+// Use of this source code is governed by the MIT License that can be found in
+// the LICENSE file.
+
+package envconfig
+
+import (
+	"os"
+	"strings"
+)
+
+// An environment is the set of variables a specification is processed
+// against.
+type environment struct {
+	// lookup returns the value of a variable and whether it is set.
+	lookup func(key string) (string, bool)
+	// environ returns every variable in the form "key=value".
+	environ func() []string
+}
+
+// osEnv is the environment of the current process. `os.Getenv` cannot
+// differentiate between an explicitly set empty value and an unset value.
+// `os.LookupEnv` is preferred to `syscall.Getenv`, but it is only available
+// in go1.5 or newer. We're using Go build tags to use os.LookupEnv for >=go1.5
+var osEnv = environment{
+	lookup:  func(key string) (string, bool) { return lookupEnv(key) },
+	environ: os.Environ,
+}
+
+// mapEnv returns an environment backed by m.
+func mapEnv(m map[string]string) environment {
+	return environment{
+		lookup: func(key string) (string, bool) {
+			value, ok := m[key]
+			return value, ok
+		},
+		environ: func() []string {
+			vars := make([]string, 0, len(m))
+			for k, v := range m {
+				vars = append(vars, k+"="+v)
+			}
+			return vars
+		},
+	}
+}
+
+// ProcessWithEnv is the same as Process but reads variables from env instead
+// of the process environment. Passing a snapshot makes processing
+// deterministic and free of races with os.Setenv.
+func ProcessWithEnv(env map[string]string, prefix string, spec interface{}, opts ...Option) error {
+	return Process(prefix, spec, append(opts[:len(opts):len(opts)], withEnv(mapEnv(env)))...)
+}
+
+// A LookupEnvFunc returns the value of a variable and whether it is set, as
+// os.LookupEnv does.
+type LookupEnvFunc func(key string) (string, bool)
+
+// ProcessWith is the same as Process but reads variables through lookup
+// instead of the process environment, so they can come from a secret store
+// such as Vault without ever being set in the environment. As lookup cannot
+// list variables, nothing is found for remainder fields or for indexed slices
+// and maps, which ProcessWithEnv supports.
+func ProcessWith(lookup LookupEnvFunc, prefix string, spec interface{}, opts ...Option) error {
+	env := environment{
+		lookup:  lookup,
+		environ: func() []string { return nil },
+	}
+	return Process(prefix, spec, append(opts[:len(opts):len(opts)], withEnv(env))...)
+}
+
+// withEnv processes against env instead of the process environment.
+func withEnv(env environment) Option {
+	return func(o *options) {
+		o.env = env
+	}
+}
+
+// ReadMap returns every variable under prefix, keyed by its name with the
+// prefix removed. It suits configuration that has no fixed shape, such as
+// that of plugins. WithLowercaseKeys and WithPrefixInKeys change the keys.
+func ReadMap(prefix string, opts ...Option) map[string]string {
+	o := newOptions(opts)
+	prefix = normalizePrefix(prefix)
+	if prefix != "" {
+		prefix += "_"
+	}
+
+	values := make(map[string]string)
+	for _, kv := range o.env.environ() {
+		pair := strings.SplitN(kv, "=", 2)
+		if len(pair) != 2 || !strings.HasPrefix(pair[0], prefix) {
+			continue
+		}
+		key := pair[0]
+		if !o.keepPrefix {
+			key = strings.TrimPrefix(key, prefix)
+		}
+		if o.lowercaseKeys {
+			key = strings.ToLower(key)
+		}
+		values[key] = pair[1]
+	}
+	return values
+}
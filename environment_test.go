@@ -0,0 +1,114 @@
+// Copyright (c) 2016 Kelsey Hightower and others. All rights reserved.
+// Use of this source code is governed by the MIT License that can be found in
+// the LICENSE file.
+
+package envconfig
+
+import (
+	"os"
+	"reflect"
+	"testing"
+)
+
+func TestProcessWithEnv(t *testing.T) {
+	var s struct {
+		Host    string `default:"${FALLBACK_HOST}"`
+		Port    int
+		Servers []server
+		Extra   map[string]string `envconfig:",remainder"`
+	}
+	os.Clearenv()
+	os.Setenv("ENV_CONFIG_PORT", "1")
+	os.Setenv("ENV_CONFIG_OTHER", "os")
+
+	env := map[string]string{
+		"FALLBACK_HOST":             "db.internal",
+		"ENV_CONFIG_PORT":           "8080",
+		"ENV_CONFIG_SERVERS_0_HOST": "a",
+		"ENV_CONFIG_PLUGIN":         "cache",
+	}
+	if err := ProcessWithEnv(env, "env_config", &s); err != nil {
+		t.Fatal(err.Error())
+	}
+	if s.Host != "db.internal" {
+		t.Errorf("expected %s, got %s", "db.internal", s.Host)
+	}
+	if s.Port != 8080 {
+		t.Errorf("expected %d, got %d", 8080, s.Port)
+	}
+	if want := []server{{Host: "a", Port: 80}}; !reflect.DeepEqual(s.Servers, want) {
+		t.Errorf("expected %v, got %v", want, s.Servers)
+	}
+	if want := map[string]string{"PLUGIN": "cache"}; !reflect.DeepEqual(s.Extra, want) {
+		t.Errorf("expected %v, got %v", want, s.Extra)
+	}
+}
+
+func TestProcessWithEnvRequired(t *testing.T) {
+	var s struct {
+		Host string `required:"true"`
+	}
+	os.Clearenv()
+	os.Setenv("ENV_CONFIG_HOST", "localhost")
+	err := ProcessWithEnv(map[string]string{}, "env_config", &s)
+	if experr := "required key ENV_CONFIG_HOST missing value"; err == nil || err.Error() != experr {
+		t.Errorf("expected %s, got %v", experr, err)
+	}
+}
+
+func TestProcessWith(t *testing.T) {
+	var s struct {
+		Host     string `required:"true"`
+		Port     int    `default:"8080"`
+		Password string `envconfig:"DB_PASSWORD"`
+		Tags     []string
+	}
+	secrets := map[string]string{
+		"ENV_CONFIG_HOST": "db.internal",
+		"DB_PASSWORD":     "hunter2",
+		"ENV_CONFIG_TAGS": "a,b",
+	}
+	lookup := func(key string) (string, bool) {
+		value, ok := secrets[key]
+		return value, ok
+	}
+	os.Clearenv()
+	os.Setenv("ENV_CONFIG_PORT", "9090")
+	if err := ProcessWith(lookup, "env_config", &s); err != nil {
+		t.Fatal(err.Error())
+	}
+	if s.Host != "db.internal" || s.Port != 8080 || s.Password != "hunter2" {
+		t.Errorf("expected %s, %d and %s, got %s, %d and %s", "db.internal", 8080, "hunter2", s.Host, s.Port, s.Password)
+	}
+	if want := []string{"a", "b"}; !reflect.DeepEqual(s.Tags, want) {
+		t.Errorf("expected %v, got %v", want, s.Tags)
+	}
+
+	delete(secrets, "ENV_CONFIG_HOST")
+	err := ProcessWith(lookup, "env_config", &s)
+	if experr := "required key ENV_CONFIG_HOST missing value"; err == nil || err.Error() != experr {
+		t.Errorf("expected %s, got %v", experr, err)
+	}
+}
+
+func TestReadMap(t *testing.T) {
+	os.Clearenv()
+	os.Setenv("PLUGIN_CACHE_SIZE", "10")
+	os.Setenv("PLUGIN_NAME", "cache")
+	os.Setenv("PLUGINS", "ignored")
+	os.Setenv("OTHER_NAME", "ignored")
+
+	tests := []struct {
+		opts []Option
+		want map[string]string
+	}{
+		{nil, map[string]string{"CACHE_SIZE": "10", "NAME": "cache"}},
+		{[]Option{WithLowercaseKeys()}, map[string]string{"cache_size": "10", "name": "cache"}},
+		{[]Option{WithPrefixInKeys()}, map[string]string{"PLUGIN_CACHE_SIZE": "10", "PLUGIN_NAME": "cache"}},
+	}
+	for _, test := range tests {
+		if got := ReadMap("plugin", test.opts...); !reflect.DeepEqual(got, test.want) {
+			t.Errorf("expected %v, got %v", test.want, got)
+		}
+	}
+}
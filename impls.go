@@ -0,0 +1,63 @@
+// Copyright (c) 2016 Kelsey Hightower and others. All rights reserved.
+// Use of this source code is governed by the MIT License that can be found in
+// the LICENSE file.
+
+package envconfig
+
+import (
+	"fmt"
+	"reflect"
+	"sync"
+)
+
+var (
+	implsMu sync.RWMutex
+	impls   = make(map[string]func() interface{})
+)
+
+// RegisterImpl makes the value returned by factory available to interface
+// fields tagged impl:"name". The factory must return a pointer to a struct
+// that implements the field's interface; its fields are then processed as if
+// they were nested in the specification. Registering a name again replaces
+// the previous factory. RegisterImpl is safe for concurrent use.
+func RegisterImpl(name string, factory func() interface{}) {
+	if factory == nil {
+		panic("envconfig: RegisterImpl factory is nil")
+	}
+	implsMu.Lock()
+	defer implsMu.Unlock()
+	impls[name] = factory
+}
+
+// implValue stores the implementation registered under name in the interface
+// field and returns the struct it points to. A value already held by the
+// field is kept when it has the registered type, so that processing the same
+// specification again sees the values of the previous run.
+func implValue(name string, field reflect.Value) (reflect.Value, error) {
+	implsMu.RLock()
+	factory, ok := impls[name]
+	implsMu.RUnlock()
+	if !ok {
+		return reflect.Value{}, fmt.Errorf("unknown impl %q", name)
+	}
+	if field.Kind() != reflect.Interface {
+		return reflect.Value{}, fmt.Errorf("impl %q used on non-interface type %s", name, field.Type())
+	}
+
+	v := reflect.ValueOf(factory())
+	if !v.IsValid() {
+		return reflect.Value{}, fmt.Errorf("impl %q is nil, not a pointer to a struct", name)
+	}
+	if v.Kind() != reflect.Ptr || v.IsNil() || v.Elem().Kind() != reflect.Struct {
+		return reflect.Value{}, fmt.Errorf("impl %q is %s, not a pointer to a struct", name, v.Type())
+	}
+	if !v.Type().Implements(field.Type()) {
+		return reflect.Value{}, fmt.Errorf("impl %q of type %s does not implement %s", name, v.Type(), field.Type())
+	}
+
+	if !field.IsNil() && field.Elem().Type() == v.Type() && !field.Elem().IsNil() {
+		return field.Elem().Elem(), nil
+	}
+	field.Set(v)
+	return v.Elem(), nil
+}
@@ -0,0 +1,86 @@
+// Copyright (c) 2016 Kelsey Hightower and others. All rights reserved.
+// Use of this source code is governed by the MIT License that can be found in
+// the LICENSE file.
+
+package envconfig
+
+import (
+	"os"
+	"testing"
+)
+
+type storage interface {
+	Location() string
+}
+
+type s3Storage struct {
+	Bucket string `required:"true"`
+	Region string `default:"us-east-1"`
+}
+
+func (s *s3Storage) Location() string { return "s3://" + s.Bucket + "/" + s.Region }
+
+type diskStorage struct {
+	Path string `default:"/var/lib/app"`
+}
+
+func (d *diskStorage) Location() string { return "file://" + d.Path }
+
+func TestRegisterImpl(t *testing.T) {
+	RegisterImpl("s3", func() interface{} { return &s3Storage{} })
+	RegisterImpl("disk", func() interface{} { return &diskStorage{} })
+	var s struct {
+		Backend storage `impl:"s3"`
+		Cache   storage `impl:"disk"`
+	}
+	os.Clearenv()
+	os.Setenv("ENV_CONFIG_BACKEND_BUCKET", "assets")
+	os.Setenv("ENV_CONFIG_CACHE_PATH", "/tmp/cache")
+	if err := Process("env_config", &s); err != nil {
+		t.Fatal(err.Error())
+	}
+	if want := "s3://assets/us-east-1"; s.Backend == nil || s.Backend.Location() != want {
+		t.Errorf("expected %s, got %v", want, s.Backend)
+	}
+	if want := "file:///tmp/cache"; s.Cache == nil || s.Cache.Location() != want {
+		t.Errorf("expected %s, got %v", want, s.Cache)
+	}
+
+	values, err := EffectiveConfig("env_config", &s)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if values["ENV_CONFIG_BACKEND_BUCKET"] != "assets" {
+		t.Errorf("expected %s, got %s", "assets", values["ENV_CONFIG_BACKEND_BUCKET"])
+	}
+
+	os.Unsetenv("ENV_CONFIG_BACKEND_BUCKET")
+	s.Backend = nil
+	err = Process("env_config", &s)
+	if experr := "required key ENV_CONFIG_BACKEND_BUCKET missing value"; err == nil || err.Error() != experr {
+		t.Errorf("expected %s, got %v", experr, err)
+	}
+}
+
+func TestUnknownImpl(t *testing.T) {
+	var s struct {
+		Backend storage `impl:"no_such_impl"`
+	}
+	os.Clearenv()
+	err := Process("env_config", &s)
+	if experr := `envconfig: unknown impl "no_such_impl" for Backend`; err == nil || err.Error() != experr {
+		t.Errorf("expected %s, got %v", experr, err)
+	}
+}
+
+func TestNilImpl(t *testing.T) {
+	RegisterImpl("nil_storage", func() interface{} { return nil })
+	var s struct {
+		Backend storage `impl:"nil_storage"`
+	}
+	os.Clearenv()
+	err := Process("env_config", &s)
+	if experr := `envconfig: impl "nil_storage" is nil, not a pointer to a struct for Backend`; err == nil || err.Error() != experr {
+		t.Errorf("expected %s, got %v", experr, err)
+	}
+}
@@ -0,0 +1,69 @@
+// Copyright (c) 2016 Kelsey Hightower and others. All rights reserved.
+// Use of this source code is governed by the MIT License that can be found in
+// the LICENSE file.
+
+//go:build go1.18
+// +build go1.18
+
+package envconfig
+
+import (
+	"reflect"
+	"sync"
+)
+
+// Lazy holds the value of a variable that is decoded on first use rather
+// than by Process, for values that are expensive to resolve. Process stores
+// the raw value and Get decodes it as it would a field of type T, caching
+// the result. A Lazy must not be copied after it has been processed.
+type Lazy[T any] struct {
+	mu    sync.Mutex
+	info  VarInfo
+	raw   string
+	set   bool
+	done  bool
+	value T
+	err   error
+}
+
+// Get returns the decoded value. A variable that was unset, and had no
+// default, yields the zero value of T.
+func (l *Lazy[T]) Get() (T, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if !l.done {
+		var v T
+		if l.set {
+			info := l.info
+			info.Field = reflect.ValueOf(&v).Elem()
+			l.err = processVar(l.raw, info)
+		}
+		l.value, l.done = v, true
+	}
+	return l.value, l.err
+}
+
+// Key returns the name of the variable the value was read from.
+func (l *Lazy[T]) Key() string {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.info.Key
+}
+
+func (l *Lazy[T]) setLazy(value string, info VarInfo) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	var zero T
+	l.info, l.raw, l.set = info, value, true
+	l.done, l.value, l.err = false, zero, nil
+}
+
+func (l *Lazy[T]) valueType() reflect.Type {
+	return reflect.TypeOf((*T)(nil)).Elem()
+}
+
+func (l *Lazy[T]) rawValue() string {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.raw
+}
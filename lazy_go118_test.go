@@ -0,0 +1,115 @@
+// Copyright (c) 2016 Kelsey Hightower and others. All rights reserved.
+// Use of this source code is governed by the MIT License that can be found in
+// the LICENSE file.
+
+//go:build go1.18
+// +build go1.18
+
+package envconfig
+
+import (
+	"bytes"
+	"os"
+	"testing"
+)
+
+var countingDecodes int
+
+type countingDecoder string
+
+func (c *countingDecoder) Decode(value string) error {
+	countingDecodes++
+	*c = countingDecoder(value)
+	return nil
+}
+
+func TestLazy(t *testing.T) {
+	var s struct {
+		Secret  Lazy[string]
+		Port    *Lazy[int] `default:"8080"`
+		Counted Lazy[countingDecoder]
+		Unset   Lazy[string]
+	}
+	os.Clearenv()
+	os.Setenv("ENV_CONFIG_SECRET", "s3cr3t")
+	os.Setenv("ENV_CONFIG_COUNTED", "once")
+	if err := Process("env_config", &s); err != nil {
+		t.Fatal(err.Error())
+	}
+	// the raw value is captured by Process
+	os.Setenv("ENV_CONFIG_SECRET", "changed")
+
+	if v, err := s.Secret.Get(); err != nil || v != "s3cr3t" {
+		t.Errorf("expected %s, got %s %v", "s3cr3t", v, err)
+	}
+	if key := s.Secret.Key(); key != "ENV_CONFIG_SECRET" {
+		t.Errorf("expected %s, got %s", "ENV_CONFIG_SECRET", key)
+	}
+	if v, err := s.Port.Get(); err != nil || v != 8080 {
+		t.Errorf("expected %d, got %d %v", 8080, v, err)
+	}
+	if v, err := s.Unset.Get(); err != nil || v != "" {
+		t.Errorf("expected empty value, got %q %v", v, err)
+	}
+
+	countingDecodes = 0
+	for i := 0; i < 3; i++ {
+		if v, err := s.Counted.Get(); err != nil || v != "once" {
+			t.Errorf("expected %s, got %s %v", "once", v, err)
+		}
+	}
+	if countingDecodes != 1 {
+		t.Errorf("expected %d decode, got %d", 1, countingDecodes)
+	}
+}
+
+func TestLazyError(t *testing.T) {
+	var s struct {
+		Port Lazy[int]
+	}
+	os.Clearenv()
+	os.Setenv("ENV_CONFIG_PORT", "eighty")
+	if err := Process("env_config", &s); err != nil {
+		t.Fatalf("expected decoding to be deferred, got %v", err)
+	}
+	_, err := s.Port.Get()
+	v, ok := err.(*ParseError)
+	if !ok {
+		t.Fatalf("expected ParseError, got %T %v", err, err)
+	}
+	if v.KeyName != "ENV_CONFIG_PORT" {
+		t.Errorf("expected %s, got %s", "ENV_CONFIG_PORT", v.KeyName)
+	}
+}
+
+func TestUsageLazyType(t *testing.T) {
+	var s struct {
+		Port Lazy[int]
+	}
+	buf := new(bytes.Buffer)
+	if err := Usagef("env_config", &s, buf, "{{range .}}{{usage_key .}}={{usage_type .}}\n{{end}}"); err != nil {
+		t.Error(err.Error())
+	}
+	compareUsage("ENV_CONFIG_PORT=Integer\n", buf.String(), t)
+}
+
+func TestValidateLazyDefault(t *testing.T) {
+	var s struct {
+		Tok  Lazy[int]  `default:"5"`
+		Port *Lazy[int] `default:"8080"`
+	}
+	if err := ValidateSpec("env_config", &s); err != nil {
+		t.Errorf("expected no error, got %v", err)
+	}
+	if err := ValidateDefaults("env_config", &s); err != nil {
+		t.Errorf("expected no error, got %v", err)
+	}
+
+	var bad struct {
+		Tok Lazy[int] `default:"five"`
+	}
+	experr := `envconfig: Tok: invalid default "five": strconv.ParseInt: parsing "five": invalid syntax`
+	if err := ValidateSpec("env_config", &bad); err == nil || err.Error() != experr {
+		t.Errorf("expected %s, got %v", experr, err)
+	}
+}
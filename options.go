@@ -0,0 +1,304 @@
+// Copyright (c) 2016 Kelsey Hightower and others. All rights reserved.
+// Use of this source code is governed by the MIT License that can be found in
+// the LICENSE file.
+
+package envconfig
+
+import (
+	"fmt"
+	"log"
+	"reflect"
+	"strings"
+)
+
+// An Option changes how a specification is processed.
+type Option func(*options)
+
+type options struct {
+	noDefaults bool
+	requireAll bool
+
+	// requiredIgnoresDefault keeps defaults from satisfying required fields.
+	requiredIgnoresDefault bool
+
+	// requiredVar names the variable that lists additional required fields.
+	requiredVar string
+
+	onError    func(VarInfo, error) error
+	maxErrors  int
+	onComplete func(map[string]string)
+	env        environment
+
+	fallbackPrefixes []string
+	altFirst         bool
+	strictTags       bool
+	trimSpace        bool
+	logger           Logger
+	sources          []Source
+
+	// lowercaseKeys and keepPrefix shape the keys returned by ReadMap.
+	lowercaseKeys bool
+	keepPrefix    bool
+
+	// descriptions replaces the desc tags of fields in usage output.
+	descriptions map[string]string
+
+	// registered holds the defaults set by SetDefaults for the spec being
+	// processed.
+	registered map[string]string
+
+	// onResolve is called as a variable starts to be resolved, and the
+	// function it returns once it is done.
+	onResolve func(VarInfo) func()
+
+	// results collects the source of each variable for ProcessReport.
+	results *[]FieldResult
+}
+
+func newOptions(opts []Option) *options {
+	o := &options{env: osEnv}
+	for _, opt := range opts {
+		opt(o)
+	}
+	return o
+}
+
+// WithoutDefaults ignores default tags, so only variables that are present in
+// the environment are assigned. Fields whose variables are unset keep the
+// value they already had, which allows overrides to be layered on top of a
+// populated struct. Required fields must be set even if they have a default.
+func WithoutDefaults() Option {
+	return func(o *options) {
+		o.noDefaults = true
+	}
+}
+
+// WithRequireAll treats every field that is not ignored as required, unless
+// it has a default. Rather than stopping at the first, the error names every
+// variable that is unset. An error returned by a WithOnError callback in
+// place of the original is returned instead.
+func WithRequireAll() Option {
+	return func(o *options) {
+		o.requireAll = true
+	}
+}
+
+// WithRequiredIgnoresDefault makes a field tagged required:"true" fail when
+// its variable is unset even if it has a default, from its tag or from
+// SetDefaults, so that the operator must set it explicitly. By default such a
+// field is satisfied by its default.
+func WithRequiredIgnoresDefault() Option {
+	return func(o *options) {
+		o.requiredIgnoresDefault = true
+	}
+}
+
+// WithRequiredVar makes the variable name, such as MYAPP_REQUIRE, list
+// additional variables to treat as required, so that deployments can tighten
+// requirements without a rebuild. Its value is a comma-separated list of
+// keys, with or without the prefix, such as HOST,PORT; these are required on
+// top of the fields tagged required:"true". A key that the specification does
+// not read is an error. Nothing changes while the variable is unset.
+func WithRequiredVar(name string) Option {
+	return func(o *options) {
+		o.requiredVar = strings.ToUpper(name)
+	}
+}
+
+// requiresEnv reports whether info must be set by a source other than a
+// default.
+func (o *options) requiresEnv(info VarInfo) bool {
+	return o.requiredIgnoresDefault && isTrue(info.Tags.Get("required"))
+}
+
+// tags returns the tags that info is processed with: its own, plus those
+// implied by options such as WithTrimSpace.
+func (o *options) tags(info VarInfo) reflect.StructTag {
+	if o.trimSpace {
+		return withTag(info.Tags, "trim", "true")
+	}
+	return info.Tags
+}
+
+// WithOnError calls fn whenever a field fails to parse or a required field
+// is unset. Returning nil suppresses the error and leaves the field as it
+// was; returning an error, such as err itself or a wrapped version of it,
+// aborts processing with that error.
+func WithOnError(fn func(info VarInfo, err error) error) Option {
+	return func(o *options) {
+		o.onError = fn
+	}
+}
+
+// WithMaxErrors makes ProcessAll stop once it has collected n errors, and
+// mark the *Errors it returns as truncated if another field fails. A limit
+// of zero or less, the default, collects every error.
+func WithMaxErrors(n int) Option {
+	return func(o *options) {
+		o.maxErrors = n
+	}
+}
+
+// WithOnComplete calls fn once processing has succeeded, with the value of
+// every variable as reported by EffectiveConfig. It gives a single place to
+// log the configuration in use; values of secret fields are redacted.
+func WithOnComplete(fn func(values map[string]string)) Option {
+	return func(o *options) {
+		o.onComplete = fn
+	}
+}
+
+// fieldError passes err for the field described by info through the
+// WithOnError callback, if one is set.
+func (o *options) fieldError(info VarInfo, err error) error {
+	if o.onError == nil {
+		return err
+	}
+	return o.onError(info, err)
+}
+
+// WithFallbackPrefixes tries each of prefixes in order for variables that
+// are unset under the prefix passed to Process. The fallback prefixes are
+// also known to CheckDisallowed and Inspect.
+func WithFallbackPrefixes(prefixes ...string) Option {
+	return func(o *options) {
+		o.fallbackPrefixes = append(o.fallbackPrefixes, prefixes...)
+	}
+}
+
+// prefixes returns prefix followed by the fallback prefixes.
+func (o *options) prefixes(prefix string) []string {
+	return append([]string{prefix}, o.fallbackPrefixes...)
+}
+
+// WithAltPrecedence controls whether the unprefixed envconfig tag name is
+// looked up before the prefixed key. By default the prefixed key wins when
+// both are set; passing true makes the tag name win instead, which helps
+// while moving from one name to the other.
+func WithAltPrecedence(altFirst bool) Option {
+	return func(o *options) {
+		o.altFirst = altFirst
+	}
+}
+
+// WithStrictTags makes processing fail when a field has a struct tag that
+// looks like a misspelling of an envconfig tag, such as requird:"true", which
+// would otherwise be ignored. Tags of other packages, such as json and yaml,
+// are left alone.
+func WithStrictTags() Option {
+	return func(o *options) {
+		o.strictTags = true
+	}
+}
+
+// WithTrimSpace trims leading and trailing whitespace from every value before
+// it is parsed, as if each field were tagged trim:"true".
+func WithTrimSpace() Option {
+	return func(o *options) {
+		o.trimSpace = true
+	}
+}
+
+// WithDescriptions supplies the descriptions shown by the usage functions,
+// keyed by field name, or by the dotted path of a nested field. A
+// description found in the map is used in place of the field's desc tag. This
+// lets descriptions be kept in doc comments and extracted by a generator.
+func WithDescriptions(descriptions map[string]string) Option {
+	return func(o *options) {
+		o.descriptions = descriptions
+	}
+}
+
+// WithSources sets the order in which the sources of each value are tried,
+// among SourceEnv and SourceDefault; the first to hold a value wins. A source
+// left out is not consulted, so WithSources(SourceEnv) ignores default tags.
+// The default order is SourceEnv, then SourceDefault. A sources tag such as
+// sources:"default,env" sets the order of a single field. WithSources panics
+// on any other source.
+func WithSources(sources ...Source) Option {
+	for _, src := range sources {
+		if src != SourceEnv && src != SourceDefault {
+			panic(fmt.Sprintf("envconfig: WithSources source %q is not supported", src))
+		}
+	}
+	sources = append([]Source{}, sources...)
+	return func(o *options) {
+		o.sources = sources
+	}
+}
+
+// A Logger receives the diagnostics enabled by WithLogger. *log.Logger
+// satisfies it.
+type Logger interface {
+	Printf(format string, v ...interface{})
+}
+
+// WithLogger logs how each variable is resolved to l: the names checked,
+// where the value was found and, unless the field is tagged secret:"true",
+// the value itself. Deprecation warnings go to l too, instead of the
+// standard logger. This helps to find out why a variable is not picked up.
+func WithLogger(l Logger) Option {
+	return func(o *options) {
+		o.logger = l
+	}
+}
+
+// WithLowercaseKeys makes ReadMap lowercase the keys it returns.
+func WithLowercaseKeys() Option {
+	return func(o *options) {
+		o.lowercaseKeys = true
+	}
+}
+
+// WithPrefixInKeys makes ReadMap return keys with the prefix left in place.
+func WithPrefixInKeys() Option {
+	return func(o *options) {
+		o.keepPrefix = true
+	}
+}
+
+// logVar reports to the logger of o how the variable of info resolved.
+func (o *options) logVar(info VarInfo, value string, src Source) {
+	if o.logger == nil {
+		return
+	}
+	var keys []string
+	for _, c := range candidates(info, o) {
+		keys = append(keys, c.key)
+	}
+	checked := strings.Join(keys, ", ")
+	if src == SourceUnset {
+		o.logger.Printf("envconfig: %s: checked %s, not found", info.Path, checked)
+		return
+	}
+	if isTrue(info.Tags.Get("secret")) {
+		value = redacted
+	}
+	o.logger.Printf("envconfig: %s: checked %s, using %q from %s", info.Path, checked, value, src)
+}
+
+// record adds the source of the variable described by info to the results
+// of o, if they are being collected.
+func (o *options) record(info VarInfo, value string, src Source) {
+	if o.results == nil {
+		return
+	}
+	if isTrue(info.Tags.Get("secret")) && value != "" {
+		value = redacted
+	}
+	*o.results = append(*o.results, FieldResult{
+		Key:       info.Key,
+		FieldName: info.Name,
+		Value:     value,
+		Source:    src,
+	})
+}
+
+// warnf logs a warning to the logger of o, or to the standard logger.
+func (o *options) warnf(format string, v ...interface{}) {
+	if o.logger != nil {
+		o.logger.Printf(format, v...)
+		return
+	}
+	log.Printf(format, v...)
+}
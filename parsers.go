@@ -0,0 +1,71 @@
+// Copyright (c) 2016 Kelsey Hightower and others. All rights reserved.
+// Use of this source code is governed by the MIT License that can be found in
+// the LICENSE file.
+
+package envconfig
+
+import (
+	"fmt"
+	"reflect"
+	"sync"
+)
+
+// A ParserFunc parses the value of a variable into a value that can be
+// assigned to the field it was registered for.
+type ParserFunc func(value string) (interface{}, error)
+
+var (
+	parsersMu sync.RWMutex
+	parsers   = make(map[string]ParserFunc)
+)
+
+// RegisterParser makes fn available to fields tagged parser:"name". This
+// allows custom parsing of types that do not implement Decoder without
+// wrapping them. Registering a name again replaces the previous parser.
+// RegisterParser is safe for concurrent use.
+func RegisterParser(name string, fn ParserFunc) {
+	if fn == nil {
+		panic("envconfig: RegisterParser parser is nil")
+	}
+	parsersMu.Lock()
+	defer parsersMu.Unlock()
+	parsers[name] = fn
+}
+
+// lookupParser returns the parser registered under name.
+func lookupParser(name string) (ParserFunc, bool) {
+	parsersMu.RLock()
+	defer parsersMu.RUnlock()
+	fn, ok := parsers[name]
+	return fn, ok
+}
+
+// processParser assigns the result of the named parser to field.
+func processParser(name, value string, field reflect.Value) error {
+	fn, ok := lookupParser(name)
+	if !ok {
+		return fmt.Errorf("envconfig: unknown parser %q", name)
+	}
+	result, err := fn(value)
+	if err != nil {
+		return err
+	}
+
+	typ := field.Type()
+	if result == nil {
+		field.Set(reflect.Zero(typ))
+		return nil
+	}
+	v := reflect.ValueOf(result)
+	switch {
+	case v.Type().AssignableTo(typ):
+		field.Set(v)
+	case typ.Kind() == reflect.Ptr && v.Type().AssignableTo(typ.Elem()):
+		ptr := reflect.New(typ.Elem())
+		ptr.Elem().Set(v)
+		field.Set(ptr)
+	default:
+		return fmt.Errorf("envconfig: parser %q returned %T, not %s", name, result, typ)
+	}
+	return nil
+}
@@ -0,0 +1,79 @@
+// Copyright (c) 2016 Kelsey Hightower and others. All rights reserved.
+// Use of this source code is governed by the MIT License that can be found in
+// the LICENSE file.
+
+package envconfig
+
+import (
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func parseSemicolonInts(value string) (interface{}, error) {
+	var ints []int
+	for _, s := range strings.Split(value, ";") {
+		i, err := strconv.Atoi(s)
+		if err != nil {
+			return nil, err
+		}
+		ints = append(ints, i)
+	}
+	return ints, nil
+}
+
+func TestRegisterParser(t *testing.T) {
+	RegisterParser("semicolon_ints", parseSemicolonInts)
+	var s struct {
+		Data []int  `parser:"semicolon_ints"`
+		Ptr  *[]int `parser:"semicolon_ints"`
+	}
+	os.Clearenv()
+	os.Setenv("ENV_CONFIG_DATA", "1;2;3")
+	os.Setenv("ENV_CONFIG_PTR", "4")
+	if err := Process("env_config", &s); err != nil {
+		t.Fatal(err.Error())
+	}
+	if want := []int{1, 2, 3}; !reflect.DeepEqual(s.Data, want) {
+		t.Errorf("expected %v, got %v", want, s.Data)
+	}
+	if s.Ptr == nil || !reflect.DeepEqual(*s.Ptr, []int{4}) {
+		t.Errorf("expected %v, got %v", []int{4}, s.Ptr)
+	}
+
+	os.Setenv("ENV_CONFIG_DATA", "1;x")
+	err := Process("env_config", &s)
+	if _, ok := err.(*ParseError); !ok {
+		t.Errorf("expected ParseError, got %T %v", err, err)
+	}
+}
+
+func TestRegisterParserTypeMismatch(t *testing.T) {
+	RegisterParser("semicolon_ints", parseSemicolonInts)
+	var s struct {
+		Data []string `parser:"semicolon_ints"`
+	}
+	os.Clearenv()
+	os.Setenv("ENV_CONFIG_DATA", "1;2")
+	err := Process("env_config", &s)
+	v, ok := err.(*ParseError)
+	if !ok {
+		t.Fatalf("expected ParseError, got %T %v", err, err)
+	}
+	if experr := `envconfig: parser "semicolon_ints" returned []int, not []string`; v.Err.Error() != experr {
+		t.Errorf("expected %s, got %v", experr, v.Err)
+	}
+}
+
+func TestUnknownParser(t *testing.T) {
+	var s struct {
+		Data []int `parser:"no_such_parser"`
+	}
+	os.Clearenv()
+	err := Process("env_config", &s)
+	if experr := `envconfig: unknown parser "no_such_parser" for Data`; err == nil || err.Error() != experr {
+		t.Errorf("expected %s, got %v", experr, err)
+	}
+}
@@ -0,0 +1,95 @@
+// Copyright (c) 2016 Kelsey Hightower and others. All rights reserved.
+// Use of this source code is governed by the MIT License that can be found in
+// the LICENSE file.
+
+package envconfig
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// providerNameRegexp matches the names of default providers.
+var providerNameRegexp = regexp.MustCompile(`^[a-z][a-z0-9_]*$`)
+
+var (
+	providersMu sync.RWMutex
+	providers   = map[string]func() string{
+		"hostname": func() string {
+			name, _ := os.Hostname()
+			return name
+		},
+		"pid": func() string {
+			return strconv.Itoa(os.Getpid())
+		},
+		"program": func() string {
+			if len(os.Args) == 0 {
+				return ""
+			}
+			return filepath.Base(os.Args[0])
+		},
+	}
+)
+
+// RegisterDefaultProvider makes fn available to defaults as ${@name}, so
+// that default:"${@name}" yields the result of fn when the variable is
+// unset. The providers hostname, pid and program, the base name of the
+// executable, are built in. Names must be lowercase. Registering a name
+// again replaces the previous provider. RegisterDefaultProvider is safe for
+// concurrent use.
+func RegisterDefaultProvider(name string, fn func() string) {
+	if fn == nil {
+		panic("envconfig: RegisterDefaultProvider provider is nil")
+	}
+	if !isProviderName(name) {
+		panic(fmt.Sprintf("envconfig: RegisterDefaultProvider name %q is not lowercase", name))
+	}
+	providersMu.Lock()
+	defer providersMu.Unlock()
+	providers[name] = fn
+}
+
+// isProviderName reports whether name is a valid provider name.
+func isProviderName(name string) bool {
+	return providerNameRegexp.MatchString(name)
+}
+
+// providerRef returns the provider named by a ${@name} reference in a
+// default. Any other reference names an environment variable, whatever its
+// case.
+func providerRef(ref string) (string, bool) {
+	if !strings.HasPrefix(ref, "@") {
+		return "", false
+	}
+	return ref[1:], true
+}
+
+// lookupProvider returns the provider registered under name.
+func lookupProvider(name string) (func() string, bool) {
+	providersMu.RLock()
+	defer providersMu.RUnlock()
+	fn, ok := providers[name]
+	return fn, ok
+}
+
+// checkProviders returns an error for the first provider referenced by def
+// that is not registered.
+func checkProviders(def string) error {
+	var err error
+	scanDefault(def, func(name, fallback string) string {
+		provider, ok := providerRef(name)
+		if err != nil || !ok {
+			return ""
+		}
+		if _, ok := lookupProvider(provider); !ok {
+			err = fmt.Errorf("unknown default provider %q", provider)
+		}
+		return ""
+	})
+	return err
+}
@@ -0,0 +1,71 @@
+// Copyright (c) 2016 Kelsey Hightower and others. All rights reserved.
+// Use of this source code is governed by the MIT License that can be found in
+// the LICENSE file.
+
+package envconfig
+
+import (
+	"os"
+	"strconv"
+	"testing"
+)
+
+func TestDefaultProviders(t *testing.T) {
+	RegisterDefaultProvider("region", func() string { return "eu-west-1" })
+	var s struct {
+		Host    string `default:"${@hostname}"`
+		PID     int    `default:"${@pid}"`
+		Region  string `default:"${@region}"`
+		Service string `default:"${SERVICE_NAME:-api}-${@region}"`
+	}
+	os.Clearenv()
+	os.Setenv("SERVICE_NAME", "billing")
+	if err := Process("env_config", &s); err != nil {
+		t.Fatal(err.Error())
+	}
+	if host, _ := os.Hostname(); s.Host != host {
+		t.Errorf("expected %s, got %s", host, s.Host)
+	}
+	if s.PID != os.Getpid() {
+		t.Errorf("expected %d, got %d", os.Getpid(), s.PID)
+	}
+	if s.Region != "eu-west-1" {
+		t.Errorf("expected %s, got %s", "eu-west-1", s.Region)
+	}
+	if want := "billing-eu-west-1"; s.Service != want {
+		t.Errorf("expected %s, got %s", want, s.Service)
+	}
+
+	os.Setenv("ENV_CONFIG_PID", strconv.Itoa(1))
+	if err := Process("env_config", &s); err != nil {
+		t.Fatal(err.Error())
+	}
+	if s.PID != 1 {
+		t.Errorf("expected %d, got %d", 1, s.PID)
+	}
+}
+
+func TestUnknownDefaultProvider(t *testing.T) {
+	var s struct {
+		Zone string `default:"${@zone:-a}"`
+	}
+	os.Clearenv()
+	err := Process("env_config", &s)
+	if experr := `envconfig: unknown default provider "zone" for Zone`; err == nil || err.Error() != experr {
+		t.Errorf("expected %s, got %v", experr, err)
+	}
+}
+
+func TestLowercaseDefaultReference(t *testing.T) {
+	var s struct {
+		Proxy string `default:"${http_proxy}"`
+	}
+	os.Clearenv()
+	os.Setenv("http_proxy", "http://proxy:3128")
+	if err := Process("env_config", &s); err != nil {
+		t.Fatal(err.Error())
+	}
+	if s.Proxy != "http://proxy:3128" {
+		t.Errorf("expected %s, got %s", "http://proxy:3128", s.Proxy)
+	}
+}
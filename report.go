@@ -0,0 +1,334 @@
+// Copyright (c) 2016 Kelsey Hightower and others. All rights reserved.
+// Use of this source code is governed by the MIT License that can be found in
+// the LICENSE file.
+
+package envconfig
+
+import (
+	"encoding"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+)
+
+// redacted replaces the value of fields tagged secret:"true" wherever values
+// are reported.
+const redacted = "****"
+
+// A Source identifies where the value of a variable came from.
+type Source string
+
+const (
+	// SourceEnv means the value was read from the prefixed key.
+	SourceEnv Source = "env"
+	// SourceAlt means the value was read from the unprefixed envconfig tag name.
+	SourceAlt Source = "alt"
+	// SourceDeprecated means the value was read from the deprecated_name tag.
+	SourceDeprecated Source = "deprecated"
+	// SourceDefault means the value came from the default tag.
+	SourceDefault Source = "default"
+	// SourceUnset means no value was found.
+	SourceUnset Source = "unset"
+)
+
+// A Report describes how each variable of a specification resolves against
+// the environment.
+type Report struct {
+	Fields []FieldResult
+	// Unused lists the variables under the prefix that no field reads.
+	Unused []string
+}
+
+// FieldResult describes how a single variable was resolved.
+type FieldResult struct {
+	Key       string
+	FieldName string
+	Value     string
+	Source    Source
+	Parsed    bool
+	Err       error
+}
+
+// Inspect resolves every variable of the specified struct and reports the
+// outcome for each, without stopping at the first error. The values are
+// parsed into a scratch copy, so spec itself is left untouched.
+func Inspect(prefix string, spec interface{}, opts ...Option) (*Report, error) {
+	prefix = normalizePrefix(prefix)
+	o := newOptions(opts)
+	s := reflect.ValueOf(spec)
+	if s.Kind() != reflect.Ptr || s.Elem().Kind() != reflect.Struct {
+		return nil, ErrInvalidSpecification
+	}
+	scratch := reflect.New(s.Elem().Type())
+
+	infos, err := gatherInfoWith(prefix, scratch.Interface(), o)
+	if err != nil {
+		return nil, err
+	}
+	rem, err := remainderInfo(infos)
+	if err != nil {
+		return nil, err
+	}
+	if err := requireListed(prefix, infos, o); err != nil {
+		return nil, err
+	}
+
+	defaults := defaultValues(spec, o)
+	o.registered = registeredDefaultsFor(spec)
+
+	report := &Report{}
+	// results and unset index the fields by path for the required_if check
+	results := make(map[string]int)
+	unset := make(map[string]bool)
+	for i := 0; i < len(infos); i++ {
+		info := infos[i]
+		if info.Remainder {
+			continue
+		}
+		info.Tags = o.tags(info)
+
+		value, src := resolve(info, o)
+		if info.Indexed && info.Field.Kind() == reflect.Map {
+			if src != SourceUnset {
+				err := processVar(value, info)
+				report.Fields = append(report.Fields, FieldResult{
+					Key:       info.Key,
+					FieldName: info.Name,
+					Value:     value,
+					Source:    src,
+					Parsed:    err == nil,
+					Err:       err,
+				})
+			}
+			elems, err := mapElements(info, o.env, claimedKeys(infos))
+			if err != nil {
+				return nil, err
+			}
+			for _, e := range elems {
+				infos = append(infos, e.infos...)
+			}
+			continue
+		}
+		if info.Indexed {
+			if src != SourceUnset && isTrue(info.Tags.Get("json")) {
+				err := processVar(value, info)
+				report.Fields = append(report.Fields, FieldResult{
+					Key:       info.Key,
+					FieldName: info.Name,
+					Value:     value,
+					Source:    src,
+					Parsed:    err == nil,
+					Err:       err,
+				})
+			}
+			if n := indexedLen(info.Key, o.env); n > info.Field.Len() {
+				grown := reflect.MakeSlice(info.Field.Type(), n, n)
+				reflect.Copy(grown, info.Field)
+				info.Field.Set(grown)
+			}
+			elems, err := elementInfos(info, info.Field)
+			if err != nil {
+				return nil, err
+			}
+			infos = append(infos, elems...)
+			continue
+		}
+
+		result := FieldResult{
+			Key:       info.Key,
+			FieldName: info.Name,
+			Value:     value,
+			Source:    src,
+		}
+		if src == SourceUnset {
+			assigned, err := assignDefaultValue(info, defaults)
+			switch {
+			case err != nil:
+				result.Err = err
+			case assigned:
+				result.Source = SourceDefault
+				result.Parsed = true
+				if o.requiresEnv(info) {
+					result.Err = missingError(info)
+				}
+			case o.requireAll || isTrue(info.Tags.Get("required")):
+				result.Err = missingError(info)
+			default:
+				unset[info.Path] = true
+			}
+		} else {
+			result.Err = processVar(value, info)
+			result.Parsed = result.Err == nil
+			if result.Err == nil && src == SourceDefault && o.requiresEnv(info) {
+				result.Err = missingError(info)
+			}
+		}
+		results[info.Path] = len(report.Fields)
+		report.Fields = append(report.Fields, result)
+	}
+	conditional, err := requiredIfMissing(infos, unset)
+	if err != nil {
+		return nil, err
+	}
+	for _, info := range conditional {
+		report.Fields[results[info.Path]].Err = missingError(info)
+	}
+	if rem == nil {
+		report.Unused = unusedKeys(o.prefixes(prefix), infos, o.env)
+	}
+
+	return report, nil
+}
+
+// EffectiveConfig returns the current value of every variable of the
+// specified struct, keyed by environment variable name and rendered as a
+// string. It is meant to be called after Process, to log the configuration
+// in use. The values of fields tagged secret:"true" are replaced by "****".
+func EffectiveConfig(prefix string, spec interface{}) (map[string]string, error) {
+	prefix = normalizePrefix(prefix)
+	infos, err := gatherInfo(prefix, spec)
+	if err != nil {
+		return nil, err
+	}
+
+	values := make(map[string]string, len(infos))
+	for i := 0; i < len(infos); i++ {
+		info := infos[i]
+		if isTrue(info.Tags.Get("secret")) {
+			values[info.Key] = redacted
+			continue
+		}
+		if info.Indexed && info.Field.Kind() == reflect.Map {
+			// report each entry under its indexed names
+			entries, err := mapEntryInfos(info)
+			if err != nil {
+				return nil, err
+			}
+			infos = append(infos, entries...)
+			continue
+		}
+		if info.Indexed {
+			// report each element under its indexed names
+			elems, err := elementInfos(info, info.Field)
+			if err != nil {
+				return nil, err
+			}
+			infos = append(infos, elems...)
+			continue
+		}
+		if isTrue(info.Tags.Get("json")) {
+			b, err := json.Marshal(info.Field.Interface())
+			if err != nil {
+				return nil, err
+			}
+			values[info.Key] = string(b)
+			continue
+		}
+		values[info.Key] = formatValue(info.Field, info.Tags)
+	}
+	return values, nil
+}
+
+// mapEntryInfos describes the variables of the current entries of the
+// indexed map described by info, reading from copies of the entries.
+func mapEntryInfos(info VarInfo) ([]VarInfo, error) {
+	var infos []VarInfo
+	structs := isPlainStruct(mapValueType(info.Field.Type()))
+	for _, k := range info.Field.MapKeys() {
+		v := reflect.New(info.Field.Type().Elem()).Elem()
+		v.Set(info.Field.MapIndex(k))
+		key := fmt.Sprintf("%s_%v", info.Key, k.Interface())
+		if !structs {
+			infos = append(infos, VarInfo{Name: info.Name, Key: key, Field: v, Tags: info.Tags})
+			continue
+		}
+		if v.Kind() == reflect.Ptr {
+			if v.IsNil() {
+				continue
+			}
+			v = v.Elem()
+		}
+		elems, err := gatherInfo(key, v.Addr().Interface())
+		if err != nil {
+			return nil, err
+		}
+		infos = append(infos, elems...)
+	}
+	return infos, nil
+}
+
+// formatValue renders a field's value in the form Process would accept,
+// given the field's tags: bytes are encoded as the encoding tag says, times
+// are formatted with time_format, and lists and maps are joined with their
+// separators.
+func formatValue(v reflect.Value, tags reflect.StructTag) string {
+	for v.Kind() == reflect.Ptr {
+		if v.IsNil() {
+			return ""
+		}
+		v = v.Elem()
+	}
+	if !v.CanInterface() {
+		return ""
+	}
+	if !v.CanAddr() {
+		// copy map keys and values, so that pointer methods apply
+		c := reflect.New(v.Type()).Elem()
+		c.Set(v)
+		v = c
+	}
+
+	// a Lazy is reported as the value it was given, undecoded
+	if lv, ok := v.Addr().Interface().(lazyValue); ok {
+		return lv.rawValue()
+	}
+	if layout := tags.Get("time_format"); layout != "" && v.Type() == timeType {
+		return v.Interface().(time.Time).Format(layout)
+	}
+	if m := textMarshaler(v); m != nil {
+		if text, err := m.MarshalText(); err == nil {
+			return string(text)
+		}
+	}
+	if s, ok := v.Addr().Interface().(fmt.Stringer); ok {
+		return s.String()
+	}
+	if isByteType(v.Type()) {
+		b := v.Bytes()
+		if v.Kind() == reflect.Array {
+			b = v.Slice(0, v.Len()).Bytes()
+		}
+		return encodeBytes(b, tags)
+	}
+
+	switch v.Kind() {
+	case reflect.Slice:
+		sep, _ := listSeparator(tags)
+		elemTags, _ := elementTags(v.Type().Elem(), tags)
+		vals := make([]string, v.Len())
+		for i := range vals {
+			vals[i] = formatValue(v.Index(i), elemTags)
+		}
+		return strings.Join(vals, sep)
+	case reflect.Map:
+		sep, _ := listSeparator(tags)
+		mapsep, _ := mapSeparator(tags)
+		keyTags, _ := elementTags(v.Type().Key(), tags)
+		elemTags, _ := elementTags(v.Type().Elem(), tags)
+		pairs := make([]string, 0, v.Len())
+		for _, k := range v.MapKeys() {
+			pairs = append(pairs, formatValue(k, keyTags)+mapsep+formatValue(v.MapIndex(k), elemTags))
+		}
+		sort.Strings(pairs)
+		return strings.Join(pairs, sep)
+	}
+	return fmt.Sprint(v.Interface())
+}
+
+func textMarshaler(field reflect.Value) (t encoding.TextMarshaler) {
+	interfaceFrom(field, func(v interface{}, ok *bool) { t, *ok = v.(encoding.TextMarshaler) })
+	return t
+}
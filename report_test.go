@@ -0,0 +1,266 @@
+// Copyright (c) 2016 Kelsey Hightower and others. All rights reserved.
+// Use of this source code is governed by the MIT License that can be found in
+// the LICENSE file.
+
+package envconfig
+
+import (
+	"net"
+	"net/url"
+	"os"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestInspect(t *testing.T) {
+	var s struct {
+		Port     int
+		Host     string `envconfig:"SERVICE_HOST"`
+		Debug    bool   `default:"true"`
+		User     string `required:"true"`
+		Optional string
+	}
+	os.Clearenv()
+	os.Setenv("ENV_CONFIG_PORT", "not-a-port")
+	os.Setenv("SERVICE_HOST", "127.0.0.1")
+	os.Setenv("ENV_CONFIG_PROT", "8080")
+
+	report, err := Inspect("env_config", &s)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	if s.Host != "" || s.Debug {
+		t.Errorf("expected spec to be untouched, got %+v", s)
+	}
+
+	if len(report.Fields) != 5 {
+		t.Fatalf("expected 5 fields, got %d", len(report.Fields))
+	}
+	want := []struct {
+		key    string
+		source Source
+		parsed bool
+		failed bool
+	}{
+		{"ENV_CONFIG_PORT", SourceEnv, false, true},
+		{"ENV_CONFIG_SERVICE_HOST", SourceAlt, true, false},
+		{"ENV_CONFIG_DEBUG", SourceDefault, true, false},
+		{"ENV_CONFIG_USER", SourceUnset, false, true},
+		{"ENV_CONFIG_OPTIONAL", SourceUnset, false, false},
+	}
+	for i, w := range want {
+		got := report.Fields[i]
+		if got.Key != w.key {
+			t.Errorf("field %d: expected key %s, got %s", i, w.key, got.Key)
+		}
+		if got.Source != w.source {
+			t.Errorf("%s: expected source %s, got %s", w.key, w.source, got.Source)
+		}
+		if got.Parsed != w.parsed {
+			t.Errorf("%s: expected parsed %v, got %v", w.key, w.parsed, got.Parsed)
+		}
+		if (got.Err != nil) != w.failed {
+			t.Errorf("%s: unexpected error %v", w.key, got.Err)
+		}
+	}
+	if _, ok := report.Fields[0].Err.(*ParseError); !ok {
+		t.Errorf("expected ParseError, got %T", report.Fields[0].Err)
+	}
+	if report.Fields[0].Value != "not-a-port" {
+		t.Errorf("expected raw value %q, got %q", "not-a-port", report.Fields[0].Value)
+	}
+
+	if len(report.Unused) != 1 || report.Unused[0] != "ENV_CONFIG_PROT" {
+		t.Errorf("expected unused [ENV_CONFIG_PROT], got %v", report.Unused)
+	}
+}
+
+func TestInspectConditionalRequirements(t *testing.T) {
+	var s struct {
+		TLSEnabled  bool
+		TLSCertFile string `required_if:"TLSEnabled"`
+		Host        string
+	}
+	os.Clearenv()
+	os.Setenv("ENV_CONFIG_TLSENABLED", "true")
+	os.Setenv("ENV_CONFIG_REQUIRE", "HOST")
+	report, err := Inspect("env_config", &s, WithRequiredVar("ENV_CONFIG_REQUIRE"))
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	// the same fields that Process rejects
+	for _, f := range report.Fields[1:] {
+		if experr := "required key " + f.Key + " missing value"; f.Err == nil || f.Err.Error() != experr {
+			t.Errorf("expected %s, got %v", experr, f.Err)
+		}
+	}
+
+	os.Setenv("ENV_CONFIG_TLSENABLED", "false")
+	os.Setenv("ENV_CONFIG_HOST", "localhost")
+	report, err = Inspect("env_config", &s, WithRequiredVar("ENV_CONFIG_REQUIRE"))
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	for _, f := range report.Fields {
+		if f.Err != nil {
+			t.Errorf("%s: unexpected error %v", f.Key, f.Err)
+		}
+	}
+}
+
+func TestInspectTrimSpace(t *testing.T) {
+	var s struct {
+		Endpoint url.URL
+	}
+	os.Clearenv()
+	os.Setenv("ENV_CONFIG_ENDPOINT", "https://example.com\n")
+	report, err := Inspect("env_config", &s, WithTrimSpace())
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	// the same value that Process accepts with WithTrimSpace
+	if f := report.Fields[0]; !f.Parsed || f.Err != nil {
+		t.Errorf("expected %s to parse, got %v", f.Key, f.Err)
+	}
+}
+
+func TestEffectiveConfig(t *testing.T) {
+	var s struct {
+		Port     int
+		Timeout  time.Duration
+		Users    []string
+		Codes    map[string]int
+		Started  time.Time
+		Nickname *string
+		Password string `secret:"true"`
+	}
+	os.Clearenv()
+	os.Setenv("ENV_CONFIG_PORT", "8080")
+	os.Setenv("ENV_CONFIG_TIMEOUT", "2m")
+	os.Setenv("ENV_CONFIG_USERS", "rob,ken")
+	os.Setenv("ENV_CONFIG_CODES", "red:1,blue:3")
+	os.Setenv("ENV_CONFIG_STARTED", "2016-08-16T18:57:05Z")
+	os.Setenv("ENV_CONFIG_PASSWORD", "hunter2")
+	if err := Process("env_config", &s); err != nil {
+		t.Fatal(err.Error())
+	}
+
+	got, err := EffectiveConfig("env_config", &s)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	want := map[string]string{
+		"ENV_CONFIG_PORT":     "8080",
+		"ENV_CONFIG_TIMEOUT":  "2m0s",
+		"ENV_CONFIG_USERS":    "rob,ken",
+		"ENV_CONFIG_CODES":    "blue:3,red:1",
+		"ENV_CONFIG_STARTED":  "2016-08-16T18:57:05Z",
+		"ENV_CONFIG_NICKNAME": "",
+		"ENV_CONFIG_PASSWORD": "****",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("expected %v, got %v", want, got)
+	}
+}
+
+func TestEffectiveConfigRoundTrip(t *testing.T) {
+	var s struct {
+		Endpoint url.URL
+		Mirror   *url.URL
+		Network  net.IPNet
+		Zone     *time.Location
+		Key      []byte            `encoding:"hex"`
+		Token    []byte            `encoding:"base64"`
+		Labels   map[string]string `sep:";" mapsep:"="`
+		Start    time.Time         `time_format:"2006-01-02"`
+	}
+	env := map[string]string{
+		"ENV_CONFIG_ENDPOINT": "http://x.com/a",
+		"ENV_CONFIG_MIRROR":   "https://y.com/b?q=1",
+		"ENV_CONFIG_NETWORK":  "10.0.0.0/8",
+		"ENV_CONFIG_ZONE":     "Europe/Berlin",
+		"ENV_CONFIG_KEY":      "abcd",
+		"ENV_CONFIG_TOKEN":    "aGk=",
+		"ENV_CONFIG_LABELS":   "a=1;b=2",
+		"ENV_CONFIG_START":    "2016-08-16",
+	}
+	os.Clearenv()
+	for k, v := range env {
+		os.Setenv(k, v)
+	}
+	if err := Process("env_config", &s); err != nil {
+		t.Fatal(err.Error())
+	}
+
+	got, err := EffectiveConfig("env_config", &s)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if !reflect.DeepEqual(got, env) {
+		t.Errorf("expected %v, got %v", env, got)
+	}
+}
+
+func TestEffectiveConfigLazy(t *testing.T) {
+	var s struct {
+		Token Lazy[string]
+		Port  *Lazy[int] `default:"8080"`
+		Unset Lazy[int]
+	}
+	os.Clearenv()
+	os.Setenv("ENV_CONFIG_TOKEN", "abc")
+	if err := Process("env_config", &s); err != nil {
+		t.Fatal(err.Error())
+	}
+
+	got, err := EffectiveConfig("env_config", &s)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	want := map[string]string{
+		"ENV_CONFIG_TOKEN": "abc",
+		"ENV_CONFIG_PORT":  "8080",
+		"ENV_CONFIG_UNSET": "",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("expected %v, got %v", want, got)
+	}
+}
+
+func TestWithOnComplete(t *testing.T) {
+	var s struct {
+		Host     string `default:"localhost"`
+		Port     int
+		Password string `secret:"true"`
+	}
+	os.Clearenv()
+	os.Setenv("ENV_CONFIG_PORT", "8080")
+	os.Setenv("ENV_CONFIG_PASSWORD", "hunter2")
+
+	var got map[string]string
+	onComplete := WithOnComplete(func(values map[string]string) {
+		got = values
+	})
+	if err := Process("env_config", &s, onComplete); err != nil {
+		t.Fatal(err.Error())
+	}
+	want := map[string]string{
+		"ENV_CONFIG_HOST":     "localhost",
+		"ENV_CONFIG_PORT":     "8080",
+		"ENV_CONFIG_PASSWORD": "****",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("expected %v, got %v", want, got)
+	}
+
+	got = nil
+	os.Setenv("ENV_CONFIG_PORT", "eighty")
+	if err := Process("env_config", &s, onComplete); err == nil {
+		t.Errorf("expected error, got nil")
+	}
+	if got != nil {
+		t.Errorf("expected no callback on error, got %v", got)
+	}
+}
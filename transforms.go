@@ -0,0 +1,99 @@
+// Copyright (c) 2016 Kelsey Hightower and others. All rights reserved.
+// Use of this source code is governed by the MIT License that can be found in
+// the LICENSE file.
+
+package envconfig
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+)
+
+// A TransformFunc rewrites the value of a variable before it is parsed. arg
+// is the text after the = of a transform:"name=arg" step, or empty.
+type TransformFunc func(value, arg string) (string, error)
+
+var (
+	transformsMu sync.RWMutex
+	transforms   = map[string]TransformFunc{
+		"trim": func(value, arg string) (string, error) {
+			if arg == "" {
+				return strings.TrimSpace(value), nil
+			}
+			return strings.Trim(value, arg), nil
+		},
+		"lower": func(value, _ string) (string, error) {
+			return strings.ToLower(value), nil
+		},
+		"upper": func(value, _ string) (string, error) {
+			return strings.ToUpper(value), nil
+		},
+		"trim_prefix": func(value, arg string) (string, error) {
+			return strings.TrimPrefix(value, arg), nil
+		},
+		"trim_suffix": func(value, arg string) (string, error) {
+			return strings.TrimSuffix(value, arg), nil
+		},
+	}
+)
+
+// RegisterTransform makes fn available as a step of the transform tag. The
+// steps of transform:"trim,lower,trim_prefix=redis://" are applied in order
+// to the value of a field, or to each element of a slice or map, before it
+// is parsed. The transforms trim, lower, upper, trim_prefix and trim_suffix
+// are built in. Registering a name again replaces the previous transform.
+// RegisterTransform is safe for concurrent use.
+func RegisterTransform(name string, fn TransformFunc) {
+	if fn == nil {
+		panic("envconfig: RegisterTransform transform is nil")
+	}
+	transformsMu.Lock()
+	defer transformsMu.Unlock()
+	transforms[name] = fn
+}
+
+// lookupTransform returns the transform registered under name.
+func lookupTransform(name string) (TransformFunc, bool) {
+	transformsMu.RLock()
+	defer transformsMu.RUnlock()
+	fn, ok := transforms[name]
+	return fn, ok
+}
+
+// transformStep is one step of a transform tag.
+type transformStep struct {
+	fn  TransformFunc
+	arg string
+}
+
+// parseTransforms looks up the steps of a transform tag.
+func parseTransforms(tag string) ([]transformStep, error) {
+	var steps []transformStep
+	for _, step := range strings.Split(tag, ",") {
+		name, arg := strings.TrimSpace(step), ""
+		if i := strings.Index(name, "="); i >= 0 {
+			name, arg = name[:i], name[i+1:]
+		}
+		fn, ok := lookupTransform(name)
+		if !ok {
+			return nil, fmt.Errorf("unknown transform %q", name)
+		}
+		steps = append(steps, transformStep{fn, arg})
+	}
+	return steps, nil
+}
+
+// applyTransforms passes value through the steps of a transform tag.
+func applyTransforms(value, tag string) (string, error) {
+	steps, err := parseTransforms(tag)
+	if err != nil {
+		return "", err
+	}
+	for _, step := range steps {
+		if value, err = step.fn(value, step.arg); err != nil {
+			return "", err
+		}
+	}
+	return value, nil
+}
@@ -6,10 +6,12 @@ package envconfig
 
 import (
 	"encoding"
+	"encoding/json"
 	"fmt"
 	"io"
 	"os"
 	"reflect"
+	"sort"
 	"strconv"
 	"strings"
 	"text/tabwriter"
@@ -34,6 +36,23 @@ variables can be used:
 KEY	TYPE	DEFAULT	REQUIRED	DESCRIPTION
 {{range .}}{{usage_key .}}	{{usage_type .}}	{{usage_default .}}	{{usage_required .}}	{{usage_description .}}
 {{end}}`
+	// GroupedTableFormat constant to use to display usage as one table per
+	// section, the top-level struct a variable is nested in, with variables
+	// sorted by key within each section
+	GroupedTableFormat = `This application is configured via the environment. The following environment
+variables can be used:
+{{range usage_sections .}}
+{{with .Name}}[{{.}}]
+{{end}}KEY	TYPE	DEFAULT	REQUIRED	DESCRIPTION
+{{range .Vars}}{{usage_key .}}	{{usage_type .}}	{{usage_default .}}	{{usage_required .}}	{{usage_description .}}
+{{end}}{{end}}`
+)
+
+// ANSI escape codes used by UsageColor.
+const (
+	ansiBold  = "\x1b[1m"
+	ansiDim   = "\x1b[2m"
+	ansiReset = "\x1b[0m"
 )
 
 var (
@@ -41,6 +60,7 @@ var (
 	setterType            = reflect.TypeOf((*Setter)(nil)).Elem()
 	textUnmarshalerType   = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
 	binaryUnmarshalerType = reflect.TypeOf((*encoding.BinaryUnmarshaler)(nil)).Elem()
+	elementDecoderType    = reflect.TypeOf((*ElementDecoder)(nil)).Elem()
 )
 
 func implementsInterface(t reflect.Type) bool {
@@ -51,27 +71,112 @@ func implementsInterface(t reflect.Type) bool {
 		t.Implements(textUnmarshalerType) ||
 		reflect.PtrTo(t).Implements(textUnmarshalerType) ||
 		t.Implements(binaryUnmarshalerType) ||
-		reflect.PtrTo(t).Implements(binaryUnmarshalerType)
+		reflect.PtrTo(t).Implements(binaryUnmarshalerType) ||
+		t.Implements(elementDecoderType) ||
+		reflect.PtrTo(t).Implements(elementDecoderType)
+}
+
+// isGroupType reports whether t is a named struct that decodes itself, the
+// kind of field that toTypeDescription renders by its type name alone.
+func isGroupType(t reflect.Type) bool {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	return t.Kind() == reflect.Struct && implementsInterface(t) && t.Name() != ""
 }
 
-// toTypeDescription converts Go types into a human readable description
-func toTypeDescription(t reflect.Type) string {
+// toTypeDescription converts Go types into a human readable description,
+// taking into account any struct tags that change how a value is parsed
+func toTypeDescription(t reflect.Type, tags reflect.StructTag) string {
+	if isTrue(tags.Get("json")) {
+		return "JSON"
+	}
+	if isStructSlice(t) {
+		elem := t.Elem()
+		if elem.Kind() == reflect.Ptr {
+			elem = elem.Elem()
+		}
+		return fmt.Sprintf("Indexed list of %s", elem.Name())
+	}
+	if isIndexedMap(t, tags) {
+		if elem := mapValueType(t); isPlainStruct(elem) {
+			return fmt.Sprintf("Indexed map of %s", elem.Name())
+		}
+		return fmt.Sprintf("Indexed map of %s", toTypeDescription(t.Elem(), tags))
+	}
+	if allowed := strings.Fields(tags.Get("oneof")); len(allowed) > 0 && !isContainer(t) {
+		return "one of: " + strings.Join(allowed, ", ")
+	}
+	if e := lookupEnum(t); e != nil {
+		if e.flags {
+			sep, _ := listSeparator(tags)
+			return fmt.Sprintf("%s-separated %s flags (%s)", toSeparatorName(sep), t.Name(), strings.Join(e.names, "|"))
+		}
+		return fmt.Sprintf("%s (%s)", t.Name(), strings.Join(e.names, "|"))
+	}
+	if t.Kind() != reflect.Ptr && t.Name() != "" &&
+		(t.Implements(elementDecoderType) || reflect.PtrTo(t).Implements(elementDecoderType)) {
+		sep, _ := listSeparator(tags)
+		return fmt.Sprintf("%s-separated %s", toSeparatorName(sep), t.Name())
+	}
+	if layout := tags.Get("time_format"); layout != "" && t == timeType {
+		return fmt.Sprintf("Time (%s)", layout)
+	}
+	switch t {
+	case ipType:
+		return "IP Address"
+	case ipNetType:
+		return "CIDR"
+	case hardwareAddrType:
+		return "MAC Address"
+	case urlType:
+		return "URL"
+	}
 	switch t.Kind() {
 	case reflect.Array, reflect.Slice:
 		if t.Elem().Kind() == reflect.Uint8 {
+			enc := tags.Get("encoding")
+			switch {
+			case t.Kind() == reflect.Array && enc != "":
+				return fmt.Sprintf("%d-byte %s", t.Len(), enc)
+			case t.Kind() == reflect.Array:
+				return fmt.Sprintf("%d-byte String", t.Len())
+			case enc != "":
+				return enc + " bytes"
+			}
+			return "String"
+		}
+		if isRunes(t, tags) {
 			return "String"
 		}
-		return fmt.Sprintf("Comma-separated list of %s", toTypeDescription(t.Elem()))
+		sep, _ := listSeparator(tags)
+		elemTags, _ := elementTags(t.Elem(), tags)
+		return fmt.Sprintf("%s-separated list of %s", toSeparatorName(sep), toTypeDescription(t.Elem(), elemTags))
 	case reflect.Map:
+		if isTrue(tags.Get("query")) {
+			return "Query String"
+		}
+		sep, _ := listSeparator(tags)
+		mapsep, _ := mapSeparator(tags)
+		valueTags, _ := elementTags(t.Elem(), tags)
 		return fmt.Sprintf(
-			"Comma-separated list of %s:%s pairs",
-			toTypeDescription(t.Key()),
-			toTypeDescription(t.Elem()),
+			"%s-separated list of %s%s%s pairs",
+			toSeparatorName(sep),
+			toTypeDescription(t.Key(), tags),
+			mapsep,
+			toTypeDescription(t.Elem(), valueTags),
 		)
 	case reflect.Ptr:
-		return toTypeDescription(t.Elem())
+		return toTypeDescription(t.Elem(), tags)
 	case reflect.Struct:
-		if implementsInterface(t) && t.Name() != "" {
+		if t == locationType {
+			return "Timezone"
+		}
+		if reflect.PtrTo(t).Implements(lazyValueType) {
+			lv := reflect.New(t).Interface().(lazyValue)
+			return toTypeDescription(lv.valueType(), tags)
+		}
+		if isGroupType(t) {
 			return t.Name()
 		}
 		return ""
@@ -93,7 +198,7 @@ func toTypeDescription(t reflect.Type) string {
 			return name
 		}
 		return "Integer"
-	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
 		name := t.Name()
 		if name != "" && !strings.HasPrefix(name, "uint") {
 			return name
@@ -105,31 +210,120 @@ func toTypeDescription(t reflect.Type) string {
 			return name
 		}
 		return "Float"
+	case reflect.Complex64, reflect.Complex128:
+		name := t.Name()
+		if name != "" && !strings.HasPrefix(name, "complex") {
+			return name
+		}
+		return "Complex"
 	}
 	return fmt.Sprintf("%+v", t)
 }
 
+// toSeparatorName names sep in a type description, quoting it when it is not
+// a valid separator.
+func toSeparatorName(sep string) string {
+	if name, ok := separatorNames[sep]; ok {
+		return name
+	}
+	return strconv.Quote(sep)
+}
+
 // Usage writes usage information to stdout using the default header and table format
-func Usage(prefix string, spec interface{}) error {
+func Usage(prefix string, spec interface{}, opts ...Option) error {
+	return usageTable(prefix, spec, os.Stdout, opts)
+}
+
+// PrintUsage writes usage information to stderr using the default header and
+// table format. Errors are discarded so it can be called from a flag.Usage
+// function.
+func PrintUsage(prefix string, spec interface{}, opts ...Option) {
+	usageTable(prefix, spec, os.Stderr, opts)
+}
+
+// MustPrintUsage is the same as PrintUsage but panics if an error occurs
+func MustPrintUsage(prefix string, spec interface{}, opts ...Option) {
+	if err := usageTable(prefix, spec, os.Stderr, opts); err != nil {
+		panic(err)
+	}
+}
+
+// usageTable writes usage information to out using the default table format
+func usageTable(prefix string, spec interface{}, out io.Writer, opts []Option) error {
 	// The default is to output the usage information as a table
 	// Create tabwriter instance to support table output
-	tabs := tabwriter.NewWriter(os.Stdout, 1, 0, 4, ' ', 0)
+	tabs := tabwriter.NewWriter(out, 1, 0, 4, ' ', 0)
 
-	err := Usagef(prefix, spec, tabs, DefaultTableFormat)
+	err := Usagef(prefix, spec, tabs, DefaultTableFormat, opts...)
 	tabs.Flush()
 	return err
 }
 
+// UsageColor writes usage information to out using the default header and
+// table format, with dim keys and bold required markers when out is a
+// terminal. The output is the same as that of Usage when out is not a
+// terminal or the NO_COLOR variable is set.
+func UsageColor(prefix string, spec interface{}, out io.Writer, opts ...Option) error {
+	if noColor, _ := lookupEnv("NO_COLOR"); noColor != "" || !isTerminal(out) {
+		return usageTable(prefix, spec, out, opts)
+	}
+	return usageColorTable(prefix, spec, out, opts)
+}
+
+// usageColorTable writes usage information to out using the default table
+// format, with bold headers, and colorUsageFuncs.
+func usageColorTable(prefix string, spec interface{}, out io.Writer, opts []Option) error {
+	// the headers of the colored columns get escape codes of the same width
+	format := strings.NewReplacer(
+		"KEY\t", ansiBold+"KEY"+ansiReset+"\t",
+		"\tREQUIRED\t", "\t"+ansiBold+"REQUIRED"+ansiReset+"\t",
+	).Replace(DefaultTableFormat)
+	tmpl, err := template.New("envconfig").Funcs(colorUsageFuncs()).Parse(format)
+	if err != nil {
+		return err
+	}
+
+	tabs := tabwriter.NewWriter(out, 1, 0, 4, ' ', 0)
+	err = Usaget(prefix, spec, tabs, tmpl, opts...)
+	tabs.Flush()
+	return err
+}
+
+// isTerminal reports whether out is a file connected to a terminal.
+func isTerminal(out io.Writer) bool {
+	f, ok := out.(*os.File)
+	if !ok {
+		return false
+	}
+	fi, err := f.Stat()
+	return err == nil && fi.Mode()&os.ModeCharDevice != 0
+}
+
 // Usagef writes usage information to the specified io.Writer using the specified template specification
-func Usagef(prefix string, spec interface{}, out io.Writer, format string) error {
-
-	// Specify the default usage template functions
-	functions := template.FuncMap{
-		"usage_key":         func(v varInfo) string { return v.Key },
-		"usage_description": func(v varInfo) string { return v.Tags.Get("desc") },
-		"usage_type":        func(v varInfo) string { return toTypeDescription(v.Field.Type()) },
-		"usage_default":     func(v varInfo) string { return v.Tags.Get("default") },
-		"usage_required": func(v varInfo) (string, error) {
+func Usagef(prefix string, spec interface{}, out io.Writer, format string, opts ...Option) error {
+	tmpl, err := template.New("envconfig").Funcs(usageFuncs()).Parse(format)
+	if err != nil {
+		return err
+	}
+
+	return Usaget(prefix, spec, out, tmpl, opts...)
+}
+
+// usageFuncs returns the default usage template functions
+func usageFuncs() template.FuncMap {
+	return template.FuncMap{
+		"usage_key":         func(v VarInfo) string { return v.Key },
+		"usage_description": func(v VarInfo) string { return v.Tags.Get("desc") },
+		"usage_type":        func(v VarInfo) string { return toTypeDescription(v.Field.Type(), v.Tags) },
+		"usage_default":     func(v VarInfo) string { return v.Tags.Get("default") },
+		"usage_current":     usageCurrent,
+		"usage_is_group":    func(v VarInfo) bool { return isGroupType(v.Field.Type()) },
+		"usage_split":       func(v VarInfo) bool { return v.SplitWords },
+		"usage_deprecated":  func(v VarInfo) string { return v.Deprecated },
+		"usage_aliases":     usageAliases,
+		"usage_section":     usageSection,
+		"usage_sections":    usageSections,
+		"usage_required": func(v VarInfo) (string, error) {
 			req := v.Tags.Get("required")
 			if req != "" {
 				reqB, err := strconv.ParseBool(req)
@@ -143,22 +337,214 @@ func Usagef(prefix string, spec interface{}, out io.Writer, format string) error
 			return req, nil
 		},
 	}
+}
 
-	tmpl, err := template.New("envconfig").Funcs(functions).Parse(format)
-	if err != nil {
-		return err
+// usageCurrent formats the present value of the field described by v, using
+// its MarshalText method if it has one. Secret values are redacted.
+func usageCurrent(v VarInfo) string {
+	if isTrue(v.Tags.Get("secret")) {
+		return redacted
 	}
+	return formatValue(v.Field, v.Tags)
+}
 
-	return Usaget(prefix, spec, out, tmpl)
+// colorUsageFuncs returns the default usage template functions, with keys
+// and required markers wrapped in ANSI escape codes. Every cell of those
+// columns is wrapped, even when empty, so that the escape codes add the same
+// width to each and the table stays aligned.
+func colorUsageFuncs() template.FuncMap {
+	funcs := usageFuncs()
+	required := funcs["usage_required"].(func(VarInfo) (string, error))
+	funcs["usage_key"] = func(v VarInfo) string { return ansiDim + v.Key + ansiReset }
+	funcs["usage_required"] = func(v VarInfo) (string, error) {
+		req, err := required(v)
+		return ansiBold + req + ansiReset, err
+	}
+	return funcs
 }
 
 // Usaget writes usage information to the specified io.Writer using the specified template
-func Usaget(prefix string, spec interface{}, out io.Writer, tmpl *template.Template) error {
+func Usaget(prefix string, spec interface{}, out io.Writer, tmpl *template.Template, opts ...Option) error {
 	// gather first
-	infos, err := gatherInfo(prefix, spec)
+	infos, err := UsageInfo(prefix, spec, opts...)
 	if err != nil {
 		return err
 	}
 
 	return tmpl.Execute(out, infos)
 }
+
+// UsageInfo returns the variables that the usage functions describe, for
+// callers that render usage themselves, as JSON for example. Besides the
+// fields of each VarInfo, such as Key and Alt, the TypeDescription, Default,
+// Required and Description methods give what the default usage table shows.
+func UsageInfo(prefix string, spec interface{}, opts ...Option) ([]VarInfo, error) {
+	prefix = normalizePrefix(prefix)
+	o := newOptions(opts)
+	infos, err := gatherInfoWith(prefix, spec, o)
+	if err != nil {
+		return nil, err
+	}
+	for i, info := range infos {
+		// supplied descriptions take the place of desc tags
+		desc, ok := o.descriptions[info.Path]
+		if !ok {
+			desc, ok = o.descriptions[info.Name]
+		}
+		if ok {
+			infos[i].Tags = withTag(info.Tags, "desc", desc)
+		}
+	}
+	return infos, nil
+}
+
+// TypeDescription describes the type of the variable, such as "Integer" or
+// "Comma-separated list of String".
+func (info VarInfo) TypeDescription() string {
+	return toTypeDescription(info.Field.Type(), info.Tags)
+}
+
+// Default returns the default tag of the variable.
+func (info VarInfo) Default() string {
+	return info.Tags.Get("default")
+}
+
+// Required reports whether the variable is tagged required:"true".
+func (info VarInfo) Required() bool {
+	return isTrue(info.Tags.Get("required"))
+}
+
+// Description returns the desc tag of the variable.
+func (info VarInfo) Description() string {
+	return info.Tags.Get("desc")
+}
+
+// usageAliases returns the names other than its key that a variable is also
+// read from, in the order they are tried.
+func usageAliases(v VarInfo) []string {
+	var aliases []string
+	for _, name := range []string{v.Alt, v.Deprecated} {
+		if name != "" && name != v.Key {
+			aliases = append(aliases, name)
+		}
+	}
+	return aliases
+}
+
+// A UsageSection is a group of variables in usage output, as returned by the
+// usage_sections template function.
+type UsageSection struct {
+	// Name is the section of the variables, empty for those of the top-level
+	// struct.
+	Name string
+	Vars []VarInfo
+}
+
+// usageSection returns the section of a variable: its usage_group tag, or
+// otherwise the top-level field it is nested in.
+func usageSection(v VarInfo) string {
+	if group := v.Tags.Get("usage_group"); group != "" {
+		return group
+	}
+	if i := strings.IndexAny(v.Path, ".["); i >= 0 {
+		return v.Path[:i]
+	}
+	return ""
+}
+
+// usageSections groups infos by section. Variables of the top-level struct
+// come first, then each section in the order it first appears; variables
+// are sorted by key within a section.
+func usageSections(infos []VarInfo) []UsageSection {
+	var sections []UsageSection
+	index := make(map[string]int)
+	if len(infos) > 0 {
+		// keep the unnamed section first, even when it is declared last
+		sections = append(sections, UsageSection{})
+		index[""] = 0
+	}
+	for _, info := range infos {
+		name := usageSection(info)
+		i, ok := index[name]
+		if !ok {
+			i = len(sections)
+			index[name] = i
+			sections = append(sections, UsageSection{Name: name})
+		}
+		sections[i].Vars = append(sections[i].Vars, info)
+	}
+	if len(sections) > 0 && len(sections[0].Vars) == 0 {
+		sections = sections[1:]
+	}
+	for _, section := range sections {
+		vars := section.Vars
+		sort.SliceStable(vars, func(i, j int) bool { return vars[i].Key < vars[j].Key })
+	}
+	return sections
+}
+
+// usageModelVar mirrors the fields of a variable available to usage
+// templates, together with the result of each default template function.
+type usageModelVar struct {
+	Name       string
+	Path       string
+	Alt        string
+	Key        string
+	Type       string
+	Tags       string
+	Deprecated string
+	Fallbacks  []string
+	Remainder  bool
+	SplitWords bool
+	Indexed    bool
+	Funcs      map[string]interface{}
+}
+
+// DebugUsageModel returns the data that usage templates are executed with,
+// formatted as indented JSON. Each variable lists the fields available to
+// templates and what the default template functions return for it, which
+// helps when writing a custom template. The options are those given to
+// Usaget, such as WithDescriptions.
+func DebugUsageModel(prefix string, spec interface{}, opts ...Option) (string, error) {
+	infos, err := UsageInfo(prefix, spec, opts...)
+	if err != nil {
+		return "", err
+	}
+
+	funcs := usageFuncs()
+	model := make([]usageModelVar, len(infos))
+	for i, info := range infos {
+		model[i] = usageModelVar{
+			Name:       info.Name,
+			Path:       info.Path,
+			Alt:        info.Alt,
+			Key:        info.Key,
+			Type:       info.Field.Type().String(),
+			Tags:       string(info.Tags),
+			Deprecated: info.Deprecated,
+			Fallbacks:  info.Fallbacks,
+			Remainder:  info.Remainder,
+			SplitWords: info.SplitWords,
+			Indexed:    info.Indexed,
+			Funcs:      make(map[string]interface{}, len(funcs)),
+		}
+		for name, fn := range funcs {
+			// functions of the whole list, such as usage_sections, are left out
+			if reflect.TypeOf(fn).In(0) != reflect.TypeOf(info) {
+				continue
+			}
+			out := reflect.ValueOf(fn).Call([]reflect.Value{reflect.ValueOf(info)})
+			if len(out) == 2 && !out[1].IsNil() {
+				model[i].Funcs[name] = out[1].Interface().(error).Error()
+				continue
+			}
+			model[i].Funcs[name] = out[0].Interface()
+		}
+	}
+
+	b, err := json.MarshalIndent(model, "", "  ")
+	if err != nil {
+		return "", err
+	}
+	return string(b), nil
+}
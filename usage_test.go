@@ -6,13 +6,18 @@ package envconfig
 
 import (
 	"bytes"
+	"encoding/json"
 	"io"
 	"io/ioutil"
 	"log"
+	"net"
 	"os"
+	"reflect"
 	"strings"
 	"testing"
 	"text/tabwriter"
+	"text/template"
+	"time"
 )
 
 var testUsageTableResult, testUsageListResult, testUsageCustomResult, testUsageBadFormatResult string
@@ -93,6 +98,38 @@ func TestUsageDefault(t *testing.T) {
 	compareUsage(testUsageTableResult, out, t)
 }
 
+func TestPrintUsage(t *testing.T) {
+	var s Specification
+	os.Clearenv()
+	save := os.Stderr
+	r, w, _ := os.Pipe()
+	os.Stderr = w
+	PrintUsage("env_config", &s)
+	outC := make(chan string)
+	go func() {
+		var buf bytes.Buffer
+		io.Copy(&buf, r)
+		outC <- buf.String()
+	}()
+	w.Close()
+	os.Stderr = save
+	out := <-outC
+
+	compareUsage(testUsageTableResult, out, t)
+}
+
+func TestMustPrintUsage(t *testing.T) {
+	defer func() {
+		if err := recover(); err != nil {
+			return
+		}
+
+		t.Error("expected panic")
+	}()
+	m := make(map[string]string)
+	MustPrintUsage("env_config", &m)
+}
+
 func TestUsageTable(t *testing.T) {
 	var s Specification
 	os.Clearenv()
@@ -153,3 +190,332 @@ func TestUsageBadFormat(t *testing.T) {
 	}
 	compareUsage(testUsageBadFormatResult, buf.String(), t)
 }
+
+func TestUsageIsGroup(t *testing.T) {
+	var s Specification
+	os.Clearenv()
+	buf := new(bytes.Buffer)
+	err := Usagef("env_config", &s, buf, "{{range .}}{{if usage_is_group .}}{{usage_key .}}\n{{end}}{{end}}")
+	if err != nil {
+		t.Error(err.Error())
+	}
+	want := "ENV_CONFIG_HONOR\nENV_CONFIG_DATETIME\nENV_CONFIG_URLVALUE\nENV_CONFIG_URLPOINTER\n"
+	compareUsage(want, buf.String(), t)
+}
+
+func TestUsageQueryType(t *testing.T) {
+	var s struct {
+		Params map[string][]string `query:"true"`
+	}
+	os.Clearenv()
+	buf := new(bytes.Buffer)
+	err := Usagef("env_config", &s, buf, "{{range .}}{{usage_key .}}={{usage_type .}}\n{{end}}")
+	if err != nil {
+		t.Error(err.Error())
+	}
+	compareUsage("ENV_CONFIG_PARAMS=Query.String\n", buf.String(), t)
+}
+
+func TestUsageEncodedBytesType(t *testing.T) {
+	var s struct {
+		Key   [32]byte `encoding:"hex"`
+		Token []byte   `encoding:"base64"`
+	}
+	os.Clearenv()
+	buf := new(bytes.Buffer)
+	err := Usagef("env_config", &s, buf, "{{range .}}{{usage_key .}}={{usage_type .}}\n{{end}}")
+	if err != nil {
+		t.Error(err.Error())
+	}
+	compareUsage("ENV_CONFIG_KEY=32-byte.hex\nENV_CONFIG_TOKEN=base64.bytes\n", buf.String(), t)
+}
+
+func TestUsageSplit(t *testing.T) {
+	var s struct {
+		MultiWordVar          string
+		MultiWordSplit        string `split_words:"true"`
+		MultiWordSplitWithAlt string `split_words:"true" envconfig:"OVERRIDE"`
+	}
+	os.Clearenv()
+	buf := new(bytes.Buffer)
+	err := Usagef("env_config", &s, buf, "{{range .}}{{usage_key .}}={{usage_split .}}\n{{end}}")
+	if err != nil {
+		t.Error(err.Error())
+	}
+	want := "ENV_CONFIG_MULTIWORDVAR=false\nENV_CONFIG_MULTI_WORD_SPLIT=true\nENV_CONFIG_OVERRIDE=false\n"
+	compareUsage(want, buf.String(), t)
+}
+
+func TestUsageLocationType(t *testing.T) {
+	var s struct {
+		TZ *time.Location
+	}
+	os.Clearenv()
+	buf := new(bytes.Buffer)
+	err := Usagef("env_config", &s, buf, "{{range .}}{{usage_key .}}={{usage_type .}}\n{{end}}")
+	if err != nil {
+		t.Error(err.Error())
+	}
+	compareUsage("ENV_CONFIG_TZ=Timezone\n", buf.String(), t)
+}
+
+func TestUsageAliases(t *testing.T) {
+	var s struct {
+		Host string `envconfig:"SERVICE_HOST" deprecated_name:"OLD_HOST"`
+		Port int
+	}
+	buf := new(bytes.Buffer)
+	format := `{{range .}}{{usage_key .}}{{with usage_aliases .}} (aliases: {{join . ", "}}){{end}}
+{{end}}`
+	tmpl, err := template.New("envconfig").Funcs(usageFuncs()).Funcs(template.FuncMap{"join": strings.Join}).Parse(format)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if err := Usaget("env_config", &s, buf, tmpl); err != nil {
+		t.Error(err.Error())
+	}
+	if expected := "ENV_CONFIG_SERVICE_HOST (aliases: SERVICE_HOST, OLD_HOST)\nENV_CONFIG_PORT\n"; buf.String() != expected {
+		t.Errorf("expected %q, got %q", expected, buf.String())
+	}
+}
+
+func TestUsageNormalizedPrefix(t *testing.T) {
+	var s struct {
+		Port int
+	}
+	buf := new(bytes.Buffer)
+	if err := Usagef("app_", &s, buf, "{{range .}}{{usage_key .}}\n{{end}}"); err != nil {
+		t.Error(err.Error())
+	}
+	compareUsage("APP_PORT\n", buf.String(), t)
+}
+
+func TestUsageColor(t *testing.T) {
+	var s struct {
+		Port int    `required:"true" desc:"listen port"`
+		Host string `default:"localhost"`
+	}
+	os.Clearenv()
+	plain := new(bytes.Buffer)
+	if err := usageTable("env_config", &s, plain, nil); err != nil {
+		t.Fatal(err.Error())
+	}
+	buf := new(bytes.Buffer)
+	if err := UsageColor("env_config", &s, buf); err != nil {
+		t.Fatal(err.Error())
+	}
+	if buf.String() != plain.String() {
+		t.Errorf("expected %q, got %q", plain.String(), buf.String())
+	}
+
+	buf.Reset()
+	if err := usageColorTable("env_config", &s, buf, nil); err != nil {
+		t.Fatal(err.Error())
+	}
+	lines := strings.Split(buf.String(), "\n")
+	want := []string{
+		"\x1b[1mKEY\x1b[0m                TYPE       DEFAULT      \x1b[1mREQUIRED\x1b[0m    DESCRIPTION",
+		"\x1b[2mENV_CONFIG_PORT\x1b[0m    Integer                 \x1b[1mtrue\x1b[0m        listen port",
+		"\x1b[2mENV_CONFIG_HOST\x1b[0m    String     localhost    \x1b[1m\x1b[0m            ",
+	}
+	if len(lines) < 6 || !reflect.DeepEqual(lines[3:6], want) {
+		t.Errorf("expected %q, got %q", want, lines)
+	}
+}
+
+func TestUsageDescriptions(t *testing.T) {
+	var s struct {
+		Port   int    `desc:"from tag"`
+		Host   string `desc:"kept"`
+		Server struct {
+			Name string
+		}
+	}
+	descriptions := map[string]string{
+		"Port":        "from comment",
+		"Server.Name": "server name",
+	}
+	buf := new(bytes.Buffer)
+	format := "{{range .}}{{usage_key .}}={{usage_description .}}\n{{end}}"
+	if err := Usagef("env_config", &s, buf, format, WithDescriptions(descriptions)); err != nil {
+		t.Fatal(err.Error())
+	}
+	want := "ENV_CONFIG_PORT=from comment\nENV_CONFIG_HOST=kept\nENV_CONFIG_SERVER_NAME=server name\n"
+	if got := buf.String(); got != want {
+		t.Errorf("expected %q, got %q", want, got)
+	}
+}
+
+func TestUsageGroupedTable(t *testing.T) {
+	var s struct {
+		HTTP struct {
+			Port int    `default:"8080"`
+			Host string `desc:"bind address"`
+		}
+		Debug bool
+		DB    struct {
+			URL string `required:"true"`
+		}
+		Cache struct {
+			Size int
+		}
+		Region string `usage_group:"Cache"`
+	}
+	buf := new(bytes.Buffer)
+	tabs := tabwriter.NewWriter(buf, 1, 0, 4, ' ', 0)
+	if err := Usagef("app", &s, tabs, GroupedTableFormat); err != nil {
+		t.Fatal(err.Error())
+	}
+	tabs.Flush()
+	want := `This application is configured via the environment. The following environment
+variables can be used:
+
+KEY          TYPE             DEFAULT    REQUIRED    DESCRIPTION
+APP_DEBUG    True or False                           
+
+[HTTP]
+KEY              TYPE       DEFAULT    REQUIRED    DESCRIPTION
+APP_HTTP_HOST    String                            bind address
+APP_HTTP_PORT    Integer    8080                   
+
+[DB]
+KEY           TYPE      DEFAULT    REQUIRED    DESCRIPTION
+APP_DB_URL    String               true        
+
+[Cache]
+KEY               TYPE       DEFAULT    REQUIRED    DESCRIPTION
+APP_CACHE_SIZE    Integer                           
+APP_REGION        String                            
+`
+	if got := buf.String(); got != want {
+		t.Errorf("expected\n%s\ngot\n%s", want, got)
+	}
+}
+
+func TestUsageInfo(t *testing.T) {
+	var s struct {
+		Port    int    `default:"8080" desc:"listen port"`
+		Host    string `envconfig:"SERVICE_HOST" required:"true"`
+		Servers []string
+	}
+	infos, err := UsageInfo("env_config", &s, WithDescriptions(map[string]string{"Host": "bind address"}))
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	// the same variables as the rendered table, with the same cells
+	buf := new(bytes.Buffer)
+	format := "{{range .}}{{usage_key .}}|{{usage_type .}}|{{usage_default .}}|{{usage_required .}}|{{usage_description .}}\n{{end}}"
+	if err := Usagef("env_config", &s, buf, format, WithDescriptions(map[string]string{"Host": "bind address"})); err != nil {
+		t.Fatal(err.Error())
+	}
+	rows := strings.Split(strings.TrimSuffix(buf.String(), "\n"), "\n")
+	if len(rows) != len(infos) {
+		t.Fatalf("expected %d variables, got %d", len(rows), len(infos))
+	}
+	for i, info := range infos {
+		required := ""
+		if info.Required() {
+			required = "true"
+		}
+		got := strings.Join([]string{info.Key, info.TypeDescription(), info.Default(), required, info.Description()}, "|")
+		if got != rows[i] {
+			t.Errorf("expected %q, got %q", rows[i], got)
+		}
+	}
+	if infos[1].Alt != "SERVICE_HOST" {
+		t.Errorf("expected %s, got %s", "SERVICE_HOST", infos[1].Alt)
+	}
+}
+
+func TestUsageCurrent(t *testing.T) {
+	var s struct {
+		Addr     net.IP `default:"0.0.0.0"`
+		Timeout  time.Duration
+		Hosts    []string
+		Password string `secret:"true"`
+		Unset    *int
+	}
+	os.Clearenv()
+	os.Setenv("ENV_CONFIG_ADDR", "10.0.0.1")
+	os.Setenv("ENV_CONFIG_TIMEOUT", "90s")
+	os.Setenv("ENV_CONFIG_HOSTS", "a,b")
+	os.Setenv("ENV_CONFIG_PASSWORD", "hunter2")
+	if err := Process("env_config", &s); err != nil {
+		t.Fatal(err.Error())
+	}
+
+	buf := new(bytes.Buffer)
+	format := "{{range .}}{{usage_key .}}={{usage_current .}}\n{{end}}"
+	if err := Usagef("env_config", &s, buf, format); err != nil {
+		t.Fatal(err.Error())
+	}
+	want := `ENV_CONFIG_ADDR=10.0.0.1
+ENV_CONFIG_TIMEOUT=1m30s
+ENV_CONFIG_HOSTS=a,b
+ENV_CONFIG_PASSWORD=****
+ENV_CONFIG_UNSET=
+`
+	if got := buf.String(); got != want {
+		t.Errorf("expected %q, got %q", want, got)
+	}
+}
+
+func TestDebugUsageModel(t *testing.T) {
+	var s struct {
+		Port int    `default:"8080" desc:"listen port" required:"true"`
+		Host string `deprecated_name:"SERVER_HOST"`
+	}
+	out, err := DebugUsageModel("env_config", &s,
+		WithDescriptions(map[string]string{"Host": "bind address"}),
+		WithFallbackPrefixes("legacy"))
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	var model []struct {
+		Name       string
+		Key        string
+		Type       string
+		Tags       string
+		Deprecated string
+		Fallbacks  []string
+		Funcs      map[string]interface{}
+	}
+	if err := json.Unmarshal([]byte(out), &model); err != nil {
+		t.Fatalf("expected JSON, got %v: %s", err, out)
+	}
+	if len(model) != 2 {
+		t.Fatalf("expected %d, got %d", 2, len(model))
+	}
+	v := model[0]
+	if v.Name != "Port" || v.Key != "ENV_CONFIG_PORT" || v.Type != "int" {
+		t.Errorf("expected %s %s %s, got %s %s %s", "Port", "ENV_CONFIG_PORT", "int", v.Name, v.Key, v.Type)
+	}
+	if expected := `default:"8080" desc:"listen port" required:"true"`; v.Tags != expected {
+		t.Errorf("expected %s, got %s", expected, v.Tags)
+	}
+	funcs := map[string]interface{}{
+		"usage_key":         "ENV_CONFIG_PORT",
+		"usage_description": "listen port",
+		"usage_type":        "Integer",
+		"usage_default":     "8080",
+		"usage_required":    "true",
+		"usage_is_group":    false,
+		"usage_split":       false,
+	}
+	for name, expected := range funcs {
+		if got := v.Funcs[name]; got != expected {
+			t.Errorf("%s: expected %v, got %v", name, expected, got)
+		}
+	}
+
+	// the variable as the template sees it, with supplied descriptions
+	v = model[1]
+	if v.Deprecated != "SERVER_HOST" || !reflect.DeepEqual(v.Fallbacks, []string{"LEGACY_HOST"}) {
+		t.Errorf("expected %s and %v, got %s and %v", "SERVER_HOST", []string{"LEGACY_HOST"}, v.Deprecated, v.Fallbacks)
+	}
+	if got := v.Funcs["usage_description"]; got != "bind address" {
+		t.Errorf("expected %s, got %v", "bind address", got)
+	}
+}
@@ -0,0 +1,394 @@
+// Copyright (c) 2016 Kelsey Hightower and others. All rights reserved.
+// Use of this source code is governed by the MIT License that can be found in
+// the LICENSE file.
+
+package envconfig
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var durationType = reflect.TypeOf(time.Duration(0))
+
+// boolTags are the tags that take a boolean value.
+var boolTags = []string{
+	"required", "split_words", "keep_default_on_empty", "json", "query",
+	"oneof_ci", "skip_empty", "secret", "from_file", "dequote",
+	"appendable", "trim_elements", "finite", "indexed", "strip_inline_comment",
+	"trim", "runes",
+}
+
+// knownTags are the struct tags read by envconfig.
+var knownTags = []string{
+	"envconfig", "default", "required", "ignored", "split_words", "desc",
+	"sep", "mapsep", "listsep", "keep_default_on_empty", "json", "query",
+	"oneof", "oneof_ci", "skip_empty", "secret", "from_file", "max_bytes",
+	"dequote", "encoding", "min", "max", "boolstyle", "parser", "impl",
+	"deprecated_name", "sources", "appendable", "trim_elements", "unit",
+	"finite", "indexed", "transform", "usage_group", "strip_inline_comment",
+	"time_format", "map_sep", "kv_sep", "required_if", "format", "trim", "runes",
+}
+
+// foreignTags are the tags of other packages that are a single edit away
+// from a known tag.
+var foreignTags = []string{"bson", "xml"}
+
+// checkTagKeys reports the first key of tag that is not a known tag but is
+// close enough to one to be a misspelling of it. Other keys are assumed to
+// belong to other packages.
+func checkTagKeys(tag reflect.StructTag) error {
+	for _, key := range tagKeys(tag) {
+		if containsString(knownTags, key) || containsString(foreignTags, key) {
+			continue
+		}
+		// allow one edit in short names, where two would match unrelated
+		// tags such as xml and max
+		limit := 1
+		if len(key) > 4 {
+			limit = 2
+		}
+		for _, known := range knownTags {
+			if editDistance(key, known) <= limit {
+				return fmt.Errorf("unknown tag %q, did you mean %q?", key, known)
+			}
+		}
+	}
+	return nil
+}
+
+// tagKeys returns the keys of tag in order, following the conventional
+// key:"value" syntax that reflect.StructTag.Get parses.
+func tagKeys(tag reflect.StructTag) []string {
+	var keys []string
+	for tag != "" {
+		i := 0
+		for i < len(tag) && tag[i] == ' ' {
+			i++
+		}
+		tag = tag[i:]
+		i = 0
+		for i < len(tag) && tag[i] > ' ' && tag[i] != ':' && tag[i] != '"' && tag[i] != 0x7f {
+			i++
+		}
+		if i == 0 || i+1 >= len(tag) || tag[i] != ':' || tag[i+1] != '"' {
+			break
+		}
+		keys = append(keys, string(tag[:i]))
+		tag = tag[i+1:]
+
+		// skip the quoted value
+		i = 1
+		for i < len(tag) && tag[i] != '"' {
+			if tag[i] == '\\' {
+				i++
+			}
+			i++
+		}
+		if i >= len(tag) {
+			break
+		}
+		tag = tag[i+1:]
+	}
+	return keys
+}
+
+// editDistance returns the number of insertions, deletions, substitutions
+// and transpositions of adjacent bytes needed to turn a into b.
+func editDistance(a, b string) int {
+	prev2 := make([]int, len(b)+1)
+	prev := make([]int, len(b)+1)
+	cur := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		cur[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			cur[j] = min3(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+			if i > 1 && j > 1 && a[i-1] == b[j-2] && a[i-2] == b[j-1] && prev2[j-2]+1 < cur[j] {
+				cur[j] = prev2[j-2] + 1
+			}
+		}
+		prev2, prev, cur = prev, cur, prev2
+	}
+	return prev[len(b)]
+}
+
+func min3(a, b, c int) int {
+	if b < a {
+		a = b
+	}
+	if c < a {
+		a = c
+	}
+	return a
+}
+
+func containsString(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+// ValidateSpec checks the struct tags of the specified struct without
+// reading the environment, and returns the first problem it finds. It is
+// meant to be called from a unit test, so that mistakes in a specification
+// are caught before it is processed in production.
+func ValidateSpec(prefix string, spec interface{}) error {
+	prefix = normalizePrefix(prefix)
+	s := reflect.ValueOf(spec)
+	if s.Kind() != reflect.Ptr || s.Elem().Kind() != reflect.Struct {
+		return ErrInvalidSpecification
+	}
+	// gather from a scratch copy, as gatherInfo allocates nil pointers
+	infos, err := gatherInfo(prefix, reflect.New(s.Elem().Type()).Interface())
+	if err != nil {
+		return err
+	}
+	if _, err := remainderInfo(infos); err != nil {
+		return err
+	}
+
+	keys := make(map[string]string, len(infos))
+	for _, info := range infos {
+		if info.Remainder {
+			continue
+		}
+		if other, ok := keys[info.Key]; ok {
+			return fmt.Errorf("envconfig: %s and %s both use key %s", other, info.Path, info.Key)
+		}
+		keys[info.Key] = info.Path
+
+		if err := validateVar(info); err != nil {
+			return fmt.Errorf("envconfig: %s: %v", info.Path, err)
+		}
+		if info.Tags.Get("required_if") != "" {
+			if _, err := requiredIfTarget(infos, info); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// validateVar checks the tags of the variable described by info.
+func validateVar(info VarInfo) error {
+	for _, name := range boolTags {
+		if v := info.Tags.Get(name); v != "" {
+			if _, err := strconv.ParseBool(v); err != nil {
+				return fmt.Errorf("invalid %s tag %q", name, v)
+			}
+		}
+	}
+
+	typ := info.Field.Type()
+	if !isTrue(info.Tags.Get("json")) && info.Tags.Get("parser") == "" && !supportedType(typ) {
+		return fmt.Errorf("unsupported type %s", typ)
+	}
+
+	def := info.Tags.Get("default")
+
+	if enc := info.Tags.Get("encoding"); enc != "" {
+		if enc != "hex" && enc != "base64" && enc != "base32" {
+			return fmt.Errorf("unknown encoding %q", enc)
+		}
+		if !isByteType(typ) {
+			return fmt.Errorf("encoding tag on non-byte type %s", typ)
+		}
+	}
+
+	if isTrue(info.Tags.Get("appendable")) && typ.Kind() != reflect.Slice {
+		return fmt.Errorf("appendable tag on non-slice type %s", typ)
+	}
+
+	if _, err := separator(info.Tags, "listsep", "|"); err != nil {
+		return err
+	}
+	sep, err := listSeparator(info.Tags)
+	if err != nil {
+		return err
+	}
+	mapsep, err := mapSeparator(info.Tags)
+	if err != nil {
+		return err
+	}
+	if sep == mapsep {
+		return fmt.Errorf("sep and mapsep are both %q", sep)
+	}
+
+	for _, name := range []string{"min", "max"} {
+		if bound := info.Tags.Get(name); bound != "" && typ == durationType {
+			if _, err := time.ParseDuration(bound); err != nil {
+				return fmt.Errorf("invalid %s %q", name, bound)
+			}
+		}
+	}
+
+	if isTrue(info.Tags.Get("runes")) && (typ.Kind() != reflect.Slice || typ.Elem() != runeType) {
+		return fmt.Errorf("runes tag on non-rune slice type %s", typ)
+	}
+
+	if isTrue(info.Tags.Get("finite")) && !isFloatType(typ) {
+		return fmt.Errorf("finite tag on non-float type %s", typ)
+	}
+
+	if info.Tags.Get("time_format") != "" && !isTimeType(typ) {
+		return fmt.Errorf("time_format tag on non-time type %s", typ)
+	}
+
+	if unit := info.Tags.Get("unit"); unit != "" {
+		if typ != durationType {
+			return fmt.Errorf("unit tag on non-duration type %s", typ)
+		}
+		if _, err := time.ParseDuration("1" + unit); err != nil {
+			return fmt.Errorf("unknown unit %q", unit)
+		}
+	}
+
+	if format := info.Tags.Get("format"); format != "" && format != "json" {
+		return fmt.Errorf("unknown format %q", format)
+	}
+
+	if style := info.Tags.Get("boolstyle"); style != "" && style != "numeric" {
+		return fmt.Errorf("unknown boolstyle %q", style)
+	}
+
+	if max := info.Tags.Get("max_bytes"); max != "" {
+		if n, err := strconv.ParseInt(max, 0, 64); err != nil || n < 0 {
+			return fmt.Errorf("invalid max_bytes %q", max)
+		}
+	}
+
+	return validateDefault(info, def)
+}
+
+// validateDefault parses def into a throwaway value of the type of info's
+// field.
+func validateDefault(info VarInfo, def string) error {
+	// defaults that reference other variables can only be checked once they
+	// are expanded, and file-backed defaults are paths
+	if def == "" || strings.Contains(def, "${") || isTrue(info.Tags.Get("from_file")) || info.Indexed {
+		return nil
+	}
+	scratch := reflect.New(defaultType(info.Field.Type())).Elem()
+	if err := processField(def, scratch, info.Tags); err != nil {
+		return fmt.Errorf("invalid default %q: %v", def, err)
+	}
+	return nil
+}
+
+// defaultType returns the type that a default for a field of type t is
+// parsed into: the type a Lazy decodes to, or t itself.
+func defaultType(t reflect.Type) reflect.Type {
+	elem := t
+	for elem.Kind() == reflect.Ptr {
+		elem = elem.Elem()
+	}
+	if reflect.PtrTo(elem).Implements(lazyValueType) {
+		return reflect.New(elem).Interface().(lazyValue).valueType()
+	}
+	return t
+}
+
+// ValidateDefaults parses the default of every variable of the specified
+// struct, from its default tag or from SetDefaults, and reports each one that
+// fails to parse. Unlike Process, which only parses a default when its
+// variable is unset, it does not depend on the environment, so a bad
+// default is caught in CI rather than by the deployment that first omits
+// the variable. Options that change how values are parsed, such as
+// WithTrimSpace, apply as they would to Process.
+func ValidateDefaults(prefix string, spec interface{}, opts ...Option) error {
+	prefix = normalizePrefix(prefix)
+	o := newOptions(opts)
+	s := reflect.ValueOf(spec)
+	if s.Kind() != reflect.Ptr || s.Elem().Kind() != reflect.Struct {
+		return ErrInvalidSpecification
+	}
+	infos, err := gatherInfo(prefix, reflect.New(s.Elem().Type()).Interface())
+	if err != nil {
+		return err
+	}
+	registered := registeredDefaultsFor(spec)
+
+	var problems []string
+	for _, info := range infos {
+		if info.Remainder {
+			continue
+		}
+		info.Tags = o.tags(info)
+		def := info.Tags.Get("default")
+		if def == "" {
+			def = registered[info.Path]
+		}
+		if err := validateDefault(info, def); err != nil {
+			problems = append(problems, fmt.Sprintf("%s: %v", info.Path, err))
+		}
+	}
+	if len(problems) > 0 {
+		return fmt.Errorf("envconfig: %s", strings.Join(problems, "; "))
+	}
+	return nil
+}
+
+// isFloatType reports whether t is a float, or a pointer to or slice of
+// floats.
+func isFloatType(t reflect.Type) bool {
+	for t.Kind() == reflect.Ptr || t.Kind() == reflect.Slice {
+		t = t.Elem()
+	}
+	return t.Kind() == reflect.Float32 || t.Kind() == reflect.Float64
+}
+
+// isTimeType reports whether t is a time.Time, or a pointer to or slice of
+// them.
+func isTimeType(t reflect.Type) bool {
+	for t.Kind() == reflect.Ptr || t.Kind() == reflect.Slice {
+		t = t.Elem()
+	}
+	return t == timeType
+}
+
+// isByteType reports whether t is a byte slice or array, or a pointer to one.
+func isByteType(t reflect.Type) bool {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	return (t.Kind() == reflect.Slice || t.Kind() == reflect.Array) && t.Elem().Kind() == reflect.Uint8
+}
+
+// supportedType reports whether processField can assign a value to a field
+// of type t.
+func supportedType(t reflect.Type) bool {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if implementsInterface(t) || isKnownType(t) || lookupEnum(t) != nil {
+		return true
+	}
+
+	switch t.Kind() {
+	case reflect.String, reflect.Bool,
+		reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr,
+		reflect.Float32, reflect.Float64, reflect.Complex64, reflect.Complex128:
+		return true
+	case reflect.Slice:
+		return isStructSlice(t) || supportedType(t.Elem())
+	case reflect.Array:
+		return t.Elem().Kind() == reflect.Uint8
+	case reflect.Map:
+		return isIndexedMap(t, "") || supportedType(t.Key()) && supportedType(t.Elem())
+	}
+	return false
+}
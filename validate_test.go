@@ -0,0 +1,193 @@
+// Copyright (c) 2016 Kelsey Hightower and others. All rights reserved.
+// Use of this source code is governed by the MIT License that can be found in
+// the LICENSE file.
+
+package envconfig
+
+import (
+	"net/url"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestValidateSpec(t *testing.T) {
+	var s struct {
+		Host    string `required:"true"`
+		Port    int    `default:"8080"`
+		Region  string `required:"true" default:"us-east-1"`
+		Timeout time.Duration
+		Token   []byte `encoding:"base64"`
+		Labels  map[string]string
+		Servers []server
+		Extra   map[string]string `envconfig:",remainder"`
+	}
+	os.Clearenv()
+	os.Setenv("ENV_CONFIG_PORT", "not-a-port")
+	if err := ValidateSpec("env_config", &s); err != nil {
+		t.Errorf("expected no error, got %v", err)
+	}
+}
+
+func TestValidateSpecErrors(t *testing.T) {
+	tests := []struct {
+		spec   interface{}
+		experr string
+	}{
+		{
+			&struct {
+				Debug bool `required:"yes please"`
+			}{},
+			`envconfig: Debug: invalid required tag "yes please"`,
+		},
+		{
+			&struct {
+				Port int `default:"eighty"`
+			}{},
+			`envconfig: Port: invalid default "eighty": strconv.ParseInt: parsing "eighty": invalid syntax`,
+		},
+		{
+			&struct {
+				Key []byte `encoding:"base58"`
+			}{},
+			`envconfig: Key: unknown encoding "base58"`,
+		},
+		{
+			&struct {
+				Name string `encoding:"hex"`
+			}{},
+			"envconfig: Name: encoding tag on non-byte type string",
+		},
+		{
+			&struct {
+				Labels map[string]string `sep:":"`
+			}{},
+			`envconfig: Labels: sep and mapsep are both ":"`,
+		},
+		{
+			&struct {
+				Skew time.Duration `min:"-5 minutes"`
+			}{},
+			`envconfig: Skew: invalid min "-5 minutes"`,
+		},
+		{
+			&struct {
+				Events chan string
+			}{},
+			"envconfig: Events: unsupported type chan string",
+		},
+		{
+			&struct {
+				Host    string
+				Address string `envconfig:"HOST"`
+			}{},
+			"envconfig: Host and Address both use key ENV_CONFIG_HOST",
+		},
+		{
+			&struct {
+				Inner struct {
+					Ratio float64 `default:"half"`
+				}
+			}{},
+			`envconfig: Inner.Ratio: invalid default "half": strconv.ParseFloat: parsing "half": invalid syntax`,
+		},
+		{
+			&struct {
+				Password string `from_file:"true" max_bytes:"-1"`
+			}{},
+			`envconfig: Password: invalid max_bytes "-1"`,
+		},
+		{
+			&struct {
+				Port int `appendable:"true"`
+			}{},
+			`envconfig: Port: appendable tag on non-slice type int`,
+		},
+		{
+			&struct {
+				Timeout time.Duration `unit:"days"`
+			}{},
+			`envconfig: Timeout: unknown unit "days"`,
+		},
+		{
+			&struct {
+				Hosts []string `sep:"::"`
+			}{},
+			`envconfig: Hosts: invalid sep "::"`,
+		},
+		{
+			&struct {
+				Labels map[string]string `kv_sep:","`
+			}{},
+			`envconfig: Labels: sep and mapsep are both ","`,
+		},
+		{
+			&struct {
+				Port int `finite:"true"`
+			}{},
+			`envconfig: Port: finite tag on non-float type int`,
+		},
+		{
+			&struct {
+				Start string `time_format:"2006-01-02"`
+			}{},
+			`envconfig: Start: time_format tag on non-time type string`,
+		},
+		{
+			&struct {
+				Rules map[string]string `format:"yaml"`
+			}{},
+			`envconfig: Rules: unknown format "yaml"`,
+		},
+		{
+			&struct {
+				Charset string `runes:"true"`
+			}{},
+			`envconfig: Charset: runes tag on non-rune slice type string`,
+		},
+	}
+	for _, test := range tests {
+		err := ValidateSpec("env_config", test.spec)
+		if err == nil || err.Error() != test.experr {
+			t.Errorf("expected %s, got %v", test.experr, err)
+		}
+	}
+}
+
+type defaultsSpec struct {
+	Port    int           `default:"8080"`
+	Timeout time.Duration `default:"soon"`
+	Ratio   float64
+	Debug   bool `default:"${DEBUG}"`
+}
+
+func TestValidateDefaults(t *testing.T) {
+	var valid struct {
+		Port    int           `default:"8080"`
+		Timeout time.Duration `default:"5s"`
+	}
+	os.Clearenv()
+	if err := ValidateDefaults("env_config", &valid); err != nil {
+		t.Errorf("expected no error, got %v", err)
+	}
+
+	var padded struct {
+		Endpoint url.URL `default:"https://example.com\n"`
+	}
+	if err := ValidateDefaults("env_config", &padded, WithTrimSpace()); err != nil {
+		t.Errorf("expected no error, got %v", err)
+	}
+
+	if err := SetDefaults(&defaultsSpec{}, map[string]string{"Ratio": "half"}); err != nil {
+		t.Fatal(err.Error())
+	}
+	defer SetDefaults(&defaultsSpec{}, nil)
+	// the environment does not hide a bad default
+	os.Setenv("ENV_CONFIG_TIMEOUT", "5s")
+	err := ValidateDefaults("env_config", &defaultsSpec{})
+	experr := `envconfig: Timeout: invalid default "soon": time: invalid duration "soon"; ` +
+		`Ratio: invalid default "half": strconv.ParseFloat: parsing "half": invalid syntax`
+	if err == nil || err.Error() != experr {
+		t.Errorf("expected %s, got %v", experr, err)
+	}
+}